@@ -2,6 +2,7 @@ package slark
 
 import (
 	"context"
+	"errors"
 	"github.com/go-slark/slark/registry"
 	"github.com/go-slark/slark/transport"
 	"github.com/google/uuid"
@@ -15,12 +16,23 @@ import (
 )
 
 type App struct {
-	servers  []transport.Server
-	signals  []os.Signal
-	registry registry.Registry
-	name     string
-	version  string
-	metadata map[string]string
+	ctx             context.Context
+	cancel          context.CancelFunc
+	l               sync.RWMutex
+	svc             *registry.Service
+	servers         []transport.Server
+	signals         []os.Signal
+	registry        registry.Registry
+	id              string
+	name            string
+	version         string
+	hostname        string
+	build           BuildInfo
+	metadata        map[string]string
+	hooks           hooks
+	stopTimeout     time.Duration
+	readyTimeout    time.Duration
+	registryTimeout time.Duration
 }
 
 type Option func(*App)
@@ -43,6 +55,13 @@ func Registry(r registry.Registry) Option {
 	}
 }
 
+// ID :instance id, defaults to env SLARK_INSTANCE_ID or a random uuid
+func ID(id string) Option {
+	return func(app *App) {
+		app.id = id
+	}
+}
+
 func Name(name string) Option {
 	return func(app *App) {
 		app.name = name
@@ -62,19 +81,77 @@ func Metadata(md map[string]string) Option {
 	}
 }
 
+func Context(ctx context.Context) Option {
+	return func(app *App) {
+		app.ctx = ctx
+	}
+}
+
+// StopTimeout :graceful stop timeout of each server
+func StopTimeout(tm time.Duration) Option {
+	return func(app *App) {
+		app.stopTimeout = tm
+	}
+}
+
+// ReadyTimeout :max time waiting for all servers ready
+func ReadyTimeout(tm time.Duration) Option {
+	return func(app *App) {
+		app.readyTimeout = tm
+	}
+}
+
+func RegistryTimeout(tm time.Duration) Option {
+	return func(app *App) {
+		app.registryTimeout = tm
+	}
+}
+
 func NewApp(opts ...Option) *App {
 	app := &App{
-		signals: []os.Signal{syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT, syscall.SIGSEGV},
+		ctx:             context.Background(),
+		signals:         []os.Signal{syscall.SIGQUIT, syscall.SIGTERM, syscall.SIGINT, syscall.SIGSEGV},
+		stopTimeout:     5 * time.Second,
+		readyTimeout:    10 * time.Second,
+		registryTimeout: 10 * time.Second,
 	}
 	for _, opt := range opts {
 		opt(app)
 	}
+	app.hostname, _ = os.Hostname()
+	app.build = readBuildInfo()
+	if len(app.id) == 0 {
+		app.id = os.Getenv(InstanceID)
+	}
+	if len(app.id) == 0 {
+		app.id = uuid.New().String()
+	}
+	app.ctx, app.cancel = context.WithCancel(NewContext(app.ctx, app))
 	return app
 }
 
 func (a *App) Run() error {
-	c := make(chan os.Signal, 1)
-	eg, ctx := errgroup.WithContext(context.TODO())
+	return a.RunContext(context.Background())
+}
+
+// RunContext blocks until a signal is received, ctx is done, Stop is called or any server exits
+func (a *App) RunContext(ctx context.Context) error {
+	ctx = NewContext(ctx, a)
+	err := start(ctx, a.hooks.beforeStart)
+	if err != nil {
+		return err
+	}
+
+	// the requests served carry the app info, but are not cancelled with the app
+	base := transport.ValueContext(context.Background(), ctx)
+	for _, srv := range a.servers {
+		if c, ok := srv.(transport.Contextual); ok {
+			c.SetBaseContext(base)
+		}
+	}
+
+	done := make(chan struct{})
+	eg, ctx := errgroup.WithContext(ctx)
 	wg := sync.WaitGroup{}
 	for _, server := range a.servers {
 		wg.Add(1)
@@ -85,33 +162,85 @@ func (a *App) Run() error {
 		})
 
 		eg.Go(func() error {
-			<-c
-			cx, cancel := context.WithTimeout(ctx, 5*time.Second)
+			<-done
+			cx, cancel := context.WithTimeout(context.Background(), a.stopTimeout)
 			defer cancel()
 			return s.Stop(cx)
 		})
 	}
 	wg.Wait()
-	svc, err := a.service()
-	if err != nil {
-		return err
+
+	var (
+		errs       []error
+		registered bool
+		svc        *registry.Service
+	)
+	// register only after all servers are ready
+	err = a.ready(ctx)
+	if err == nil {
+		// fail fast if any server exits during startup
+		err = ctx.Err()
+	}
+	if err == nil {
+		svc, err = a.service()
 	}
-	if a.registry != nil {
-		cx, cancel := context.WithTimeout(ctx, 10*time.Second)
-		defer cancel()
+	if err == nil {
+		a.l.Lock()
+		a.svc = svc
+		a.l.Unlock()
+	}
+	if err == nil && a.registry != nil {
+		cx, cancel := context.WithTimeout(ctx, a.registryTimeout)
 		err = a.registry.Register(cx, svc)
-		if err != nil {
-			return err
+		cancel()
+		registered = err == nil
+	}
+	if err == nil {
+		err = start(ctx, a.hooks.afterStart)
+	}
+	if err != nil {
+		// abort startup
+		errs = append(errs, err)
+	} else {
+		c := make(chan os.Signal, 1)
+		signal.Notify(c, a.signals...)
+		select {
+		case <-c:
+		case <-a.ctx.Done():
+		case <-ctx.Done(): // parent ctx done or server exit
 		}
+		signal.Stop(c)
 	}
-	signal.Notify(c, a.signals...)
-	<-c
-	close(c)
 
-	if a.registry != nil {
-		cx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
-		defer cancel()
-		return a.registry.Unregister(cx, svc)
+	errs = append(errs, stop(context.Background(), a.hooks.beforeStop))
+	// deregister before servers drain
+	if registered {
+		cx, cancel := context.WithTimeout(context.Background(), a.registryTimeout)
+		errs = append(errs, a.registry.Unregister(cx, svc))
+		cancel()
+	}
+	close(done)
+	errs = append(errs, eg.Wait())
+	errs = append(errs, stop(context.Background(), a.hooks.afterStop))
+	return errors.Join(errs...)
+}
+
+func (a *App) Stop() {
+	a.cancel()
+}
+
+func (a *App) ready(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, a.readyTimeout)
+	defer cancel()
+	eg, cx := errgroup.WithContext(ctx)
+	for _, srv := range a.servers {
+		r, ok := srv.(transport.Ready)
+		if !ok {
+			continue
+		}
+		eg.Go(func() error {
+			return r.Ready(cx)
+		})
 	}
 	return eg.Wait()
 }
@@ -121,6 +250,17 @@ func (a *App) service() (*registry.Service, error) {
 	u := &url.URL{}
 	endpoint := make([]string, 0, len(a.servers))
 	for _, srv := range a.servers {
+		if eps, ok := srv.(transport.Endpoints); ok {
+			var us []*url.URL
+			us, err = eps.Endpoints()
+			if err != nil {
+				return nil, err
+			}
+			for _, u := range us {
+				endpoint = append(endpoint, u.String())
+			}
+			continue
+		}
 		ep, ok := srv.(transport.Endpoint)
 		if !ok {
 			continue
@@ -132,7 +272,7 @@ func (a *App) service() (*registry.Service, error) {
 		endpoint = append(endpoint, u.String())
 	}
 	svc := &registry.Service{
-		ID:       uuid.New().String(),
+		ID:       a.id,
 		Name:     a.name,
 		Version:  a.version,
 		Endpoint: endpoint,
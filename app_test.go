@@ -0,0 +1,240 @@
+package slark
+
+import (
+	"context"
+	"errors"
+	"github.com/go-slark/slark/registry"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	l      sync.Mutex
+	events []string
+}
+
+func (r *recorder) add(event string) {
+	r.l.Lock()
+	r.events = append(r.events, event)
+	r.l.Unlock()
+}
+
+type server struct {
+	rec  *recorder
+	name string
+	done chan struct{}
+}
+
+func newServer(name string, rec *recorder) *server {
+	return &server{name: name, rec: rec, done: make(chan struct{})}
+}
+
+func (s *server) Start() error {
+	<-s.done
+	return nil
+}
+
+func (s *server) Stop(ctx context.Context) error {
+	s.rec.add(s.name + " stop")
+	close(s.done)
+	return nil
+}
+
+func record(rec *recorder, name string, err error) Hook {
+	return Hook{
+		Name: name,
+		Fn: func(ctx context.Context) error {
+			rec.add(name)
+			return err
+		},
+	}
+}
+
+func TestAppStop(t *testing.T) {
+	rec := &recorder{}
+	app := NewApp(
+		Server(newServer("srv", rec)),
+		BeforeStart(record(rec, "before start", nil)),
+		AfterStart(record(rec, "after start", nil)),
+		BeforeStop(record(rec, "before stop", nil)),
+		AfterStop(record(rec, "after stop", nil)),
+	)
+	time.AfterFunc(100*time.Millisecond, app.Stop)
+	err := app.Run()
+	if err != nil {
+		t.Fatalf("run error:%+v", err)
+	}
+	expect := []string{"before start", "after start", "before stop", "srv stop", "after stop"}
+	if len(rec.events) != len(expect) {
+		t.Fatalf("events:%v, expect:%v", rec.events, expect)
+	}
+	for i := range expect {
+		if rec.events[i] != expect[i] {
+			t.Fatalf("events:%v, expect:%v", rec.events, expect)
+		}
+	}
+}
+
+func TestAppRunContext(t *testing.T) {
+	rec := &recorder{}
+	app := NewApp(Server(newServer("srv", rec)))
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := app.RunContext(ctx)
+	if err != nil {
+		t.Fatalf("run error:%+v", err)
+	}
+	if len(rec.events) != 1 {
+		t.Fatalf("server not stopped:%v", rec.events)
+	}
+}
+
+type readyServer struct {
+	*server
+	err error
+}
+
+func (s *readyServer) Start() error {
+	if s.err != nil {
+		return s.err
+	}
+	return s.server.Start()
+}
+
+func (s *readyServer) Ready(ctx context.Context) error {
+	return s.err
+}
+
+type reg struct {
+	svc []*registry.Service
+}
+
+func (r *reg) Register(ctx context.Context, svc *registry.Service) error {
+	r.svc = append(r.svc, svc)
+	return nil
+}
+
+func (r *reg) Unregister(ctx context.Context, svc *registry.Service) error {
+	r.svc = r.svc[:0]
+	return nil
+}
+
+func TestAppReady(t *testing.T) {
+	rec := &recorder{}
+	e := errors.New("listen error")
+	r := &reg{}
+	app := NewApp(
+		Registry(r),
+		Server(newServer("srv", rec), &readyServer{server: newServer("ready", rec), err: e}),
+	)
+	err := app.Run()
+	if !errors.Is(err, e) {
+		t.Fatalf("run error:%+v", err)
+	}
+	if len(r.svc) != 0 {
+		t.Fatalf("registered before ready:%+v", r.svc)
+	}
+
+	r = &reg{}
+	app = NewApp(
+		Registry(r),
+		Server(&readyServer{server: newServer("ready", rec)}),
+		AfterStart(Hook{Name: "check", Fn: func(ctx context.Context) error {
+			if len(r.svc) != 1 {
+				return errors.New("not registered")
+			}
+			return nil
+		}}),
+	)
+	time.AfterFunc(100*time.Millisecond, app.Stop)
+	err = app.Run()
+	if err != nil {
+		t.Fatalf("run error:%+v", err)
+	}
+}
+
+type endpointServer struct {
+	*server
+}
+
+func (s *endpointServer) Endpoint() (*url.URL, error) {
+	return &url.URL{Scheme: "grpc", Host: "127.0.0.1:9090"}, nil
+}
+
+func TestAppInfo(t *testing.T) {
+	t.Setenv(InstanceID, "instance-env")
+	app := NewApp(Name("test"))
+	if app.ID() != "instance-env" {
+		t.Fatalf("id:%s", app.ID())
+	}
+
+	rec := &recorder{}
+	app = NewApp(
+		ID("instance"),
+		Name("test"),
+		Server(&endpointServer{server: newServer("srv", rec)}),
+		AfterStart(Hook{Name: "info", Fn: func(ctx context.Context) error {
+			info, ok := FromContext(ctx)
+			if !ok || info.ID() != "instance" || info.Name() != "test" {
+				return errors.New("app info not found")
+			}
+			if len(info.Endpoints()) != 1 || info.Endpoints()[0] != "grpc://127.0.0.1:9090" {
+				return errors.New("app endpoints invalid")
+			}
+			return nil
+		}}),
+	)
+	time.AfterFunc(100*time.Millisecond, app.Stop)
+	err := app.Run()
+	if err != nil {
+		t.Fatalf("run error:%+v", err)
+	}
+}
+
+type endpointsServer struct {
+	*server
+}
+
+func (s *endpointsServer) Endpoints() ([]*url.URL, error) {
+	return []*url.URL{{Scheme: "http", Host: "127.0.0.1:8000"}, {Scheme: "grpc", Host: "127.0.0.1:8000"}}, nil
+}
+
+func TestAppEndpoints(t *testing.T) {
+	app := NewApp(Server(&endpointsServer{server: newServer("srv", &recorder{})}))
+	svc, err := app.service()
+	if err != nil || len(svc.Endpoint) != 2 || svc.Endpoint[0] != "http://127.0.0.1:8000" || svc.Endpoint[1] != "grpc://127.0.0.1:8000" {
+		t.Fatalf("endpoints:%v, err:%v", svc, err)
+	}
+}
+
+type contextualServer struct {
+	*server
+	base context.Context
+}
+
+func (s *contextualServer) SetBaseContext(ctx context.Context) {
+	s.base = ctx
+}
+
+func TestAppBaseContext(t *testing.T) {
+	srv := &contextualServer{server: newServer("srv", &recorder{})}
+	app := NewApp(ID("instance"), Server(srv))
+	time.AfterFunc(100*time.Millisecond, app.Stop)
+	err := app.Run()
+	if err != nil {
+		t.Fatalf("run error:%+v", err)
+	}
+	info, ok := FromContext(srv.base)
+	if !ok || info.ID() != "instance" {
+		t.Fatal("app info not in the base context")
+	}
+	if len(info.Hostname()) == 0 || len(info.Build().GoVersion) == 0 {
+		t.Fatalf("hostname:%s, build:%+v", info.Hostname(), info.Build())
+	}
+	// the requests in flight are not cancelled with the app
+	if srv.base.Err() != nil {
+		t.Fatal("base context cancelled")
+	}
+}
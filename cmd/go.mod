@@ -3,6 +3,7 @@ module github.com/go-slark/slark/cmd
 go 1.19
 
 require (
+	github.com/bufbuild/protocompile v0.8.0
 	github.com/spf13/cobra v1.8.0
 	golang.org/x/text v0.14.0
 	google.golang.org/genproto/googleapis/api v0.0.0-20240125205218-1f4bbc51befe
@@ -12,5 +13,6 @@ require (
 require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sync v0.5.0 // indirect
 	google.golang.org/genproto v0.0.0-20240116215550-a9fa1716bcac // indirect
 )
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/go-slark/slark/cmd/protoc-gen-errors/errors"
+	"go/token"
 	"strings"
 	"unicode"
 
@@ -71,7 +72,9 @@ func genErrorsReason(gen *protogen.Plugin, file *protogen.File, g *protogen.Gene
 	if code > maxErrCode || code < minErrCode {
 		panic(fmt.Sprintf("Enum '%s' range must be greater than or equal to %d and less than or equal to %d", string(enum.Desc.Name()), minErrCode, maxErrCode))
 	}
+	defaultMessage := proto.GetExtension(enum.Desc.Options(), errors.E_DefaultMessage).(string)
 	var ew errorWrapper
+	locales := make(map[string]*localeInfo)
 	for _, v := range enum.Values {
 		enumCode := code
 		eCode := proto.GetExtension(v.Desc.Options(), errors.E_Code)
@@ -92,15 +95,33 @@ func genErrorsReason(gen *protogen.Plugin, file *protogen.File, g *protogen.Gene
 			comment = v.Comments.Trailing.String()
 		}
 
+		message := proto.GetExtension(v.Desc.Options(), errors.E_Message).(string)
+		if message == "" {
+			message = defaultMessage
+		}
 		err := &errorInfo{
 			Name:       string(enum.Desc.Name()),
 			Value:      string(v.Desc.Name()),
 			CamelValue: case2Camel(string(v.Desc.Name())),
 			ErrCode:    enumCode,
+			Message:    message,
 			Comment:    comment,
 			HasComment: len(comment) > 0,
 		}
+		for _, key := range proto.GetExtension(v.Desc.Options(), errors.E_Metadata).([]string) {
+			err.Metadata = append(err.Metadata, &metadataInfo{Key: key, Param: param(key)})
+		}
 		ew.Errors = append(ew.Errors, err)
+
+		for _, l := range proto.GetExtension(v.Desc.Options(), errors.E_I18N).([]*errors.Localized) {
+			locale, ok := locales[l.Lang]
+			if !ok {
+				locale = &localeInfo{Lang: l.Lang}
+				locales[l.Lang] = locale
+				ew.Locales = append(ew.Locales, locale)
+			}
+			locale.Messages = append(locale.Messages, &errorInfo{Name: err.Name, Value: err.Value, Message: l.Message})
+		}
 	}
 	if len(ew.Errors) == 0 {
 		return true
@@ -112,6 +133,16 @@ func genErrorsReason(gen *protogen.Plugin, file *protogen.File, g *protogen.Gene
 
 var enCases = cases.Title(language.AmericanEnglish, cases.NoLower)
 
+// param converts a metadata key such as user_id to the parameter name userId
+func param(key string) string {
+	name := case2Camel(key)
+	name = strings.ToLower(name[:1]) + name[1:]
+	if token.IsKeyword(name) {
+		name += "_"
+	}
+	return name
+}
+
 func case2Camel(name string) string {
 	if !strings.Contains(name, "_") {
 		if name == strings.ToUpper(name) {
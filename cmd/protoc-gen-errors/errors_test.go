@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// generate runs the plugin against a sample proto in testdata
+func generate(t *testing.T, name string) string {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{"testdata", "../../third_party"},
+		}),
+		SourceInfoMode: protocompile.SourceInfoStandard,
+	}
+	files, err := compiler.Compile(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &pluginpb.CodeGeneratorRequest{FileToGenerate: []string{name}}
+	seen := map[string]bool{}
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fdp := protodesc.ToFileDescriptorProto(fd)
+		// re-parse the options with the registered errors extensions
+		b, e := proto.Marshal(fdp)
+		if e != nil {
+			t.Fatal(e)
+		}
+		fdp = &descriptorpb.FileDescriptorProto{}
+		e = proto.Unmarshal(b, fdp)
+		if e != nil {
+			t.Fatal(e)
+		}
+		req.ProtoFile = append(req.ProtoFile, fdp)
+	}
+	add(files[0])
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate {
+			generateFile(gen, f)
+		}
+	}
+	rsp := gen.Response()
+	if rsp.Error != nil {
+		t.Fatal(rsp.GetError())
+	}
+	if len(rsp.File) != 1 {
+		t.Fatalf("generated files:%d", len(rsp.File))
+	}
+	return rsp.File[0].GetContent()
+}
+
+func TestGolden(t *testing.T) {
+	protos, err := filepath.Glob("testdata/*.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range protos {
+		name := filepath.Base(p)
+		t.Run(name, func(t *testing.T) {
+			content := generate(t, name)
+			golden := filepath.Join("testdata", strings.TrimSuffix(name, ".proto")+".pb.go.golden")
+			if *update {
+				err := os.WriteFile(golden, []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != string(want) {
+				t.Fatalf("%s mismatch, run go test -update\n%s", golden, content)
+			}
+		})
+	}
+}
+
+func TestParam(t *testing.T) {
+	for key, name := range map[string]string{"user_id": "userId", "name": "name", "type": "type_", "ID": "id"} {
+		if p := param(key); p != name {
+			t.Fatalf("key:%s, param:%s", key, p)
+		}
+	}
+}
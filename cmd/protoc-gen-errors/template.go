@@ -5,9 +5,13 @@ import (
 	"text/template"
 )
 
+// the sentinels and the catalog are initialized before the enum descriptors, so the reasons are literals
 var errorsTemplate = `
 {{ range .Errors }}
 
+{{ if .HasComment }}{{ .Comment }}{{ end -}}
+var Err{{ .CamelValue }} = errors.New({{ .ErrCode }}, {{ printf "%q" .Message }}, {{ printf "%q" .Value }})
+
 {{ if .HasComment }}{{ .Comment }}{{ end -}}
 func Is{{.CamelValue}}(err error) bool {
 	if err == nil {
@@ -22,6 +26,32 @@ func Error{{ .CamelValue }}(format string, args ...interface{}) *errors.Error {
 	 return errors.New({{ .ErrCode }}, fmt.Sprintf(format, args...), {{ .Name }}_{{ .Value }}.String())
 }
 
+{{ if .HasComment }}{{ .Comment }}{{ end -}}
+func New{{ .CamelValue }}({{ range $i, $m := .Metadata }}{{ if $i }}, {{ end }}{{ $m.Param }} string{{ end }}) *errors.Error {
+	{{- if .Metadata }}
+	return errors.New({{ .ErrCode }}, {{ printf "%q" .Message }}, {{ .Name }}_{{ .Value }}.String()).WithMetadata(map[string]string{
+		{{- range .Metadata }}
+		{{ printf "%q" .Key }}: {{ .Param }},
+		{{- end }}
+	})
+	{{- else }}
+	return errors.New({{ .ErrCode }}, {{ printf "%q" .Message }}, {{ .Name }}_{{ .Value }}.String())
+	{{- end }}
+}
+
+{{- end }}
+
+{{- if .Locales }}
+
+func init() {
+	{{- range .Locales }}
+	errors.RegisterMessages({{ printf "%q" .Lang }}, map[string]string{
+		{{- range .Messages }}
+		{{ printf "%q" .Value }}: {{ printf "%q" .Message }},
+		{{- end }}
+	})
+	{{- end }}
+}
 {{- end }}
 `
 
@@ -30,12 +60,25 @@ type errorInfo struct {
 	Value      string
 	ErrCode    int
 	CamelValue string
+	Message    string
+	Metadata   []*metadataInfo
 	Comment    string
 	HasComment bool
 }
 
+type metadataInfo struct {
+	Key   string
+	Param string
+}
+
+type localeInfo struct {
+	Lang     string
+	Messages []*errorInfo
+}
+
 type errorWrapper struct {
-	Errors []*errorInfo
+	Errors  []*errorInfo
+	Locales []*localeInfo
 }
 
 func (e *errorWrapper) execute() string {
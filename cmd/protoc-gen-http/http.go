@@ -10,6 +10,7 @@ import (
 	"google.golang.org/protobuf/types/descriptorpb"
 	"os"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -20,6 +21,49 @@ const (
 
 var methodSets = make(map[string]int)
 
+// envelopes maps the envelope option to the envelope of transport/http
+var envelopes = map[string]string{
+	"":        "",
+	"status":  "StatusEnvelope",
+	"raw":     "RawEnvelope",
+	"problem": "ProblemEnvelope",
+}
+
+// methodEnvelopes maps the full name of a method to the envelope of its routes
+type methodEnvelopes map[string]string
+
+func (m methodEnvelopes) String() string {
+	values := make([]string, 0, len(m))
+	for method, e := range m {
+		values = append(values, method+"="+e)
+	}
+	sort.Strings(values)
+	return strings.Join(values, ",")
+}
+
+// Set parses method=envelope, such as greeter.v1.Greeter.GetUser=raw
+func (m methodEnvelopes) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i <= 0 {
+		return fmt.Errorf("invalid method envelope: %s", value)
+	}
+	e := value[i+1:]
+	if _, ok := envelopes[e]; !ok {
+		return fmt.Errorf("unknown envelope: %s", e)
+	}
+	m[value[:i]] = e
+	return nil
+}
+
+// methodEnvelope returns the envelope of the routes of the method, -envelope if not overridden
+func methodEnvelope(m *protogen.Method) string {
+	e, ok := routes[string(m.Desc.FullName())]
+	if !ok {
+		e = *envelope
+	}
+	return envelopes[e]
+}
+
 // generateFile generates a _http.pb.go file containing.
 func generateFile(gen *protogen.Plugin, file *protogen.File, omitempty bool) *protogen.GeneratedFile {
 	if len(file.Services) == 0 || (omitempty && !hasHTTPRule(file.Services)) {
@@ -66,7 +110,8 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 		Metadata:    file.Desc.Path(),
 	}
 	for _, method := range service.Methods {
-		if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+		// bidi streaming is not supported over http
+		if method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer() {
 			continue
 		}
 		rule, ok := proto.GetExtension(method.Desc.Options(), annotations.E_Http).(*annotations.HttpRule)
@@ -77,7 +122,10 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 			sd.Methods = append(sd.Methods, buildHTTPRule(g, method, rule))
 		} else if !omitempty {
 			path := fmt.Sprintf("/%s/%s", service.Desc.FullName(), method.Desc.Name())
-			sd.Methods = append(sd.Methods, buildMethodDesc(g, method, "POST", path))
+			md := buildMethodDesc(g, method, "POST", path)
+			md.Body = "*"
+			md.HasBody = true
+			sd.Methods = append(sd.Methods, md)
 		}
 	}
 	if len(sd.Methods) != 0 {
@@ -88,7 +136,7 @@ func genService(gen *protogen.Plugin, file *protogen.File, g *protogen.Generated
 func hasHTTPRule(services []*protogen.Service) bool {
 	for _, service := range services {
 		for _, method := range service.Methods {
-			if method.Desc.IsStreamingClient() || method.Desc.IsStreamingServer() {
+			if method.Desc.IsStreamingClient() && method.Desc.IsStreamingServer() {
 				continue
 			}
 			rule, ok := proto.GetExtension(method.Desc.Options(), annotations.E_Http).(*annotations.HttpRule)
@@ -131,21 +179,24 @@ func buildHTTPRule(g *protogen.GeneratedFile, m *protogen.Method, rule *annotati
 	body = rule.Body
 	responseBody = rule.ResponseBody
 	md := buildMethodDesc(g, m, method, path)
+	md.Body = body
 	if method == "GET" || method == "DELETE" {
 		if body != "" {
 			_, _ = fmt.Fprintf(os.Stderr, "\u001B[31mWARN\u001B[m: %s %s body should not be declared.\n", method, path)
 		}
 	} else {
-		if body != "*" {
+		if body == "" {
 			_, _ = fmt.Fprintf(os.Stderr, "\u001B[31mWARN\u001B[m: %s %s does not declare a body.\n", method, path)
 		}
 	}
+	// path vars, plus body or body field, plus the remaining fields from query
 	if body == "*" {
 		md.HasBody = true
 	} else {
-		if !md.HasVars {
-			md.HasQuery = true
+		if body != "" {
+			md.BodyField = "." + camelCaseVars(body)
 		}
+		md.HasQuery = true
 	}
 	if responseBody == "*" {
 		md.ResponseBody = ""
@@ -158,11 +209,8 @@ func buildHTTPRule(g *protogen.GeneratedFile, m *protogen.Method, rule *annotati
 func buildMethodDesc(g *protogen.GeneratedFile, m *protogen.Method, method, path string) *methodDesc {
 	defer func() { methodSets[m.GoName]++ }()
 	params := buildPathParams(path)
-	for k, v := range params {
+	for k := range params {
 		fields := m.Input.Desc.Fields()
-		if v != nil {
-			path = replacePath(k, *v, path)
-		}
 		for _, field := range strings.Split(k, ".") {
 			if strings.TrimSpace(field) == "" {
 				continue
@@ -193,15 +241,10 @@ func buildMethodDesc(g *protogen.GeneratedFile, m *protogen.Method, method, path
 		Path:         path,
 		Method:       method,
 		HasVars:      len(params) > 0,
+		ServerStream: m.Desc.IsStreamingServer(),
+		ClientStream: m.Desc.IsStreamingClient(),
+		Envelope:     methodEnvelope(m),
 	}
-	paths := strings.Split(md.Path, "/")
-	for index, elem := range paths {
-		l := len(elem)
-		if l > 0 && (elem[0] == '{' && elem[l-1] == '}' || elem[0] == ':') {
-			paths[index] = ":" + elem[1:l-1]
-		}
-	}
-	md.Path = strings.Join(paths, "/")
 	return md
 }
 
@@ -223,20 +266,6 @@ func buildPathParams(path string) (res map[string]*string) {
 	return
 }
 
-func replacePath(name string, value string, path string) string {
-	pattern := regexp.MustCompile(fmt.Sprintf(`(?i){([\s]*%s[\s]*)=?([^{}]*)}`, name))
-	idx := pattern.FindStringIndex(path)
-	if len(idx) > 0 {
-		path = fmt.Sprintf("%s{%s:%s}%s",
-			path[:idx[0]], // The start of the match
-			name,
-			strings.ReplaceAll(value, "*", ".*"),
-			path[idx[1]:],
-		)
-	}
-	return path
-}
-
 func camelCaseVars(s string) string {
 	subs := strings.Split(s, ".")
 	vars := make([]string, 0, len(subs))
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"github.com/bufbuild/protocompile"
+	"google.golang.org/protobuf/compiler/protogen"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/pluginpb"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// generate runs the plugin against a sample proto in testdata
+func generate(t *testing.T, name string) string {
+	compiler := protocompile.Compiler{
+		Resolver: protocompile.WithStandardImports(&protocompile.SourceResolver{
+			ImportPaths: []string{"testdata", "../../third_party"},
+		}),
+	}
+	files, err := compiler.Compile(context.Background(), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := &pluginpb.CodeGeneratorRequest{FileToGenerate: []string{name}}
+	seen := map[string]bool{}
+	var add func(fd protoreflect.FileDescriptor)
+	add = func(fd protoreflect.FileDescriptor) {
+		if seen[fd.Path()] {
+			return
+		}
+		seen[fd.Path()] = true
+		imports := fd.Imports()
+		for i := 0; i < imports.Len(); i++ {
+			add(imports.Get(i).FileDescriptor)
+		}
+		fdp := protodesc.ToFileDescriptorProto(fd)
+		// re-parse the options with the registered google.api.http extension
+		b, e := proto.Marshal(fdp)
+		if e != nil {
+			t.Fatal(e)
+		}
+		fdp = &descriptorpb.FileDescriptorProto{}
+		e = proto.Unmarshal(b, fdp)
+		if e != nil {
+			t.Fatal(e)
+		}
+		req.ProtoFile = append(req.ProtoFile, fdp)
+	}
+	add(files[0])
+
+	gen, err := protogen.Options{}.New(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range gen.Files {
+		if f.Generate {
+			generateFile(gen, f, true)
+		}
+	}
+	rsp := gen.Response()
+	if rsp.Error != nil {
+		t.Fatal(rsp.GetError())
+	}
+	if len(rsp.File) != 1 {
+		t.Fatalf("generated files:%d", len(rsp.File))
+	}
+	return rsp.File[0].GetContent()
+}
+
+func TestGolden(t *testing.T) {
+	protos, err := filepath.Glob("testdata/*.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the plugin params of a proto
+	params := map[string][][2]string{
+		"envelope.proto": {
+			{"envelope", "problem"},
+			{"method_envelope", "envelope.v1.Report.GetReport=raw"},
+			{"method_envelope", "envelope.v1.Report.ExportReport=status"},
+		},
+	}
+	for _, p := range protos {
+		name := filepath.Base(p)
+		t.Run(name, func(t *testing.T) {
+			for _, param := range params[name] {
+				err := flag.CommandLine.Set(param[0], param[1])
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			defer func() {
+				*envelope = ""
+				for method := range routes {
+					delete(routes, method)
+				}
+			}()
+			methodSets = map[string]int{}
+			content := generate(t, name)
+			golden := filepath.Join("testdata", strings.TrimSuffix(name, ".proto")+"_http.pb.go.golden")
+			if *update {
+				err := os.WriteFile(golden, []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if content != string(want) {
+				t.Fatalf("%s mismatch, run go test -update\n%s", golden, content)
+			}
+		})
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	*envelope = "problem"
+	defer func() {
+		*envelope = ""
+	}()
+	methodSets = map[string]int{}
+	content := generate(t, "greeter.proto")
+	if !strings.Contains(content, `r.Handle("GET", "/v1/users/{id}", _Greeter_GetUser0_HTTP_Handler(srv), http.RouteEnvelope(http.ProblemEnvelope))`) {
+		t.Fatalf("route envelope miss\n%s", content)
+	}
+}
+
+func TestMethodEnvelope(t *testing.T) {
+	m := methodEnvelopes{}
+	for _, value := range []string{"greeter.v1.Greeter.GetUser", "=raw", "greeter.v1.Greeter.GetUser=unknown"} {
+		if m.Set(value) == nil {
+			t.Fatalf("value:%s expected error", value)
+		}
+	}
+	if err := m.Set("greeter.v1.Greeter.GetUser=raw"); err != nil || m.String() != "greeter.v1.Greeter.GetUser=raw" {
+		t.Fatalf("envelopes:%s, error:%+v", m, err)
+	}
+}
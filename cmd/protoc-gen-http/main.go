@@ -12,8 +12,14 @@ import (
 var (
 	showVersion = flag.Bool("version", false, "print the version and exit")
 	omitempty   = flag.Bool("omitempty", true, "omit if google.api is empty")
+	envelope    = flag.String("envelope", "", "response envelope of the routes: raw or problem, the envelope of the server if empty")
+	routes      = methodEnvelopes{}
 )
 
+func init() {
+	flag.Var(routes, "method_envelope", "response envelope of the routes of a method overriding -envelope, such as greeter.v1.Greeter.GetUser=raw, repeated per method")
+}
+
 func main() {
 	flag.Parse()
 	if *showVersion {
@@ -24,6 +30,9 @@ func main() {
 		ParamFunc: flag.CommandLine.Set,
 	}.Run(func(gen *protogen.Plugin) error {
 		gen.SupportedFeatures = uint64(pluginpb.CodeGeneratorResponse_FEATURE_PROTO3_OPTIONAL)
+		if _, ok := envelopes[*envelope]; !ok {
+			return fmt.Errorf("unknown envelope: %s", *envelope)
+		}
 		for _, f := range gen.Files {
 			if !f.Generate {
 				continue
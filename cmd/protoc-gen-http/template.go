@@ -7,48 +7,101 @@ import (
 )
 
 var httpTemplate = `
+{{- define "bind"}}
+		{{- if .HasQuery}}
+		err = ctx.ShouldBindQuery(&in)
+		if err != nil {
+			return err
+		}
+		{{- end}}
+
+		{{- if .HasBody}}
+		err = ctx.ShouldBind(&in)
+		if err != nil {
+			return err
+		}
+		{{- else if .BodyField}}
+		err = ctx.ShouldBind(&in{{.BodyField}})
+		if err != nil {
+			return err
+		}
+		{{- end}}
+
+		{{- if .HasVars}}
+		err = ctx.ShouldBindURI(&in)
+		if err != nil {
+			return err
+		}
+		{{- end}}
+{{- end}}
 {{$svrType := .ServiceType}}
 
 type {{.ServiceType}}HTTPServer interface {
 {{- range .MethodSets}}
+	{{- if .ClientStream}}
+	{{.Name}}({{$svrType}}_{{.Name}}HTTPServer) error
+	{{- else if .ServerStream}}
+	{{.Name}}(*{{.Request}}, {{$svrType}}_{{.Name}}HTTPServer) error
+	{{- else}}
 	{{.Name}}(context.Context, *{{.Request}}) (*{{.Reply}}, error)
+	{{- end}}
 {{- end}}
 }
 
 func Register{{.ServiceType}}HTTPServer(s *http.Server, srv {{.ServiceType}}HTTPServer) {
 	r := http.NewRouter(s)
 	{{- range .Methods}}
-	r.Handle("{{.Method}}", "{{.Path}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv))
+	r.Handle("{{.Method}}", "{{.Path}}", _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv){{if .Envelope}}, http.RouteEnvelope(http.{{.Envelope}}){{end}})
 	{{- end}}
 }
 
 {{range .Methods}}
+{{- if .ClientStream}}
+func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv {{$svrType}}HTTPServer) http.HandlerFunc {
+	return func(ctx *http.Context) error {
+		stream := ctx.ClientStream()
+		out, err := ctx.Handle(func(ctx context.Context, req interface{}) (interface{}, error) {
+			x := &{{$svrType}}{{.Name}}HTTPServer{ClientStream: stream.WithContext(ctx)}
+			err := srv.{{.Name}}(x)
+			if err != nil {
+				return nil, err
+			}
+			if x.out == nil {
+				x.out = new({{.Reply}})
+			}
+			return x.out, nil
+		})(ctx.Context(), nil)
+		if err != nil {
+			return err
+		}
+		return ctx.Result(out.(*{{.Reply}}){{.ResponseBody}})
+	}
+}
+{{- else if .ServerStream}}
 func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv {{$svrType}}HTTPServer) http.HandlerFunc {
 	return func(ctx *http.Context) error {
 		var (
 			in {{.Request}}
-			out interface{}
 			err error
 		)
-		
-		{{- if .HasBody}}
-		err = ctx.ShouldBind(&in)
-		if err != nil {
-			return err
-		}
+		{{- template "bind" .}}
 
-		{{- else if .HasQuery}}
-		err = ctx.ShouldBindQuery(&in)
-		if err != nil {
-			return err
-		}
-
-		{{- else}}
-		err = ctx.ShouldBindURI(&in)
-		if err != nil {
-			return err
-		}
-		{{- end}}
+		stream := ctx.ServerStream()
+		_, err = ctx.Handle(func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, srv.{{.Name}}(req.(*{{.Request}}), &{{$svrType}}{{.Name}}HTTPServer{ServerStream: stream.WithContext(ctx)})
+		})(ctx.Context(), &in)
+		return stream.Finish(err)
+	}
+}
+{{- else}}
+func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv {{$svrType}}HTTPServer) http.HandlerFunc {
+	return func(ctx *http.Context) error {
+		var (
+			in {{.Request}}
+			out interface{}
+			err error
+		)
+		{{- template "bind" .}}
 
 		out, err = ctx.Handle(func(ctx context.Context, req interface{}) (interface{}, error) {
 			return srv.{{.Name}}(ctx, req.(*{{.Request}}))
@@ -56,10 +109,90 @@ func _{{$svrType}}_{{.Name}}{{.Num}}_HTTP_Handler(srv {{$svrType}}HTTPServer) ht
 		if err != nil {
 			return err
 		}
-		return ctx.Result(out.(*{{.Reply}}))
+		return ctx.Result(out.(*{{.Reply}}){{.ResponseBody}})
+	}
+}
+{{- end}}
+{{end}}
+
+{{- range .MethodSets}}
+{{- if .ClientStream}}
+type {{$svrType}}_{{.Name}}HTTPServer interface {
+	SendAndClose(*{{.Reply}}) error
+	Recv() (*{{.Request}}, error)
+	Context() context.Context
+}
+
+type {{$svrType}}{{.Name}}HTTPServer struct {
+	*http.ClientStream
+	out *{{.Reply}}
+}
+
+func (x *{{$svrType}}{{.Name}}HTTPServer) SendAndClose(m *{{.Reply}}) error {
+	x.out = m
+	return nil
+}
+
+func (x *{{$svrType}}{{.Name}}HTTPServer) Recv() (*{{.Request}}, error) {
+	m := new({{.Request}})
+	err := x.ClientStream.Recv(m)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+{{else if .ServerStream}}
+type {{$svrType}}_{{.Name}}HTTPServer interface {
+	Send(*{{.Reply}}) error
+	Context() context.Context
+}
+
+type {{$svrType}}{{.Name}}HTTPServer struct {
+	*http.ServerStream
+}
+
+func (x *{{$svrType}}{{.Name}}HTTPServer) Send(m *{{.Reply}}) error {
+	return x.ServerStream.Send(m)
+}
+{{end}}
+{{- end}}
+
+type {{.ServiceType}}HTTPClient interface {
+{{- range .MethodSets}}
+	{{- if not (or .ClientStream .ServerStream)}}
+	{{.Name}}(ctx context.Context, req *{{.Request}}) (*{{.Reply}}, error)
+	{{- end}}
+{{- end}}
+}
+
+type {{.ServiceType}}HTTPClientImpl struct {
+	cc *http.Client
+}
+
+func New{{.ServiceType}}HTTPClient(client *http.Client) {{.ServiceType}}HTTPClient {
+	return &{{.ServiceType}}HTTPClientImpl{cc: client}
+}
+
+{{range .MethodSets}}
+{{- if not (or .ClientStream .ServerStream)}}
+func (c *{{$svrType}}HTTPClientImpl) {{.Name}}(ctx context.Context, in *{{.Request}}) (*{{.Reply}}, error) {
+	var out {{.Reply}}
+	path, err := http.EncodeURL("{{.Path}}", in, {{ne .Body "*"}}{{if .BodyField}}, "{{.Body}}"{{end}})
+	if err != nil {
+		return nil, err
 	}
+	req := http.NewRequest().Method("{{.Method}}").URL(path).Decoder(http.ResponseDecoder).ErrDecoder(http.ErrorDecoder)
+	{{- if .Body}}
+	req = req.Header(map[string]string{"Content-Type": "application/json"}).Param(in{{.BodyField}})
+	{{- end}}
+	err = c.cc.DoHTTPReq(ctx, req, &out{{.ResponseBody}})
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 {{end}}
+{{- end}}
 `
 
 type serviceDesc struct {
@@ -79,12 +212,18 @@ type methodDesc struct {
 	Request      string
 	Reply        string
 	// http_rule
-	Path         string
+	Path         string // google.api.http template such as /v1/{name=orgs/*}/users
 	Method       string
 	HasVars      bool
 	HasBody      bool
 	HasQuery     bool
+	Body         string
+	BodyField    string
 	ResponseBody string
+	Envelope     string // envelope of transport/http overriding the envelope of the server
+	// streaming
+	ServerStream bool
+	ClientStream bool
 }
 
 func (s *serviceDesc) execute() string {
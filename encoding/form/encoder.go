@@ -0,0 +1,196 @@
+package form
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/go-slark/slark/errors"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EncodeValues encodes the populated fields of a proto message into url values, the reverse of the decoder
+func EncodeValues(msg proto.Message) (url.Values, error) {
+	values := url.Values{}
+	if msg == nil {
+		return values, nil
+	}
+	err := encodeMessage("", msg.ProtoReflect(), values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// EncodeField returns the url value of a field path such as a.b
+func EncodeField(msg proto.Message, path string) (string, error) {
+	v := msg.ProtoReflect()
+	fields := strings.Split(path, ".")
+	for i, field := range fields {
+		fd := getDescriptorByField(v.Descriptor().Fields(), field)
+		if fd == nil {
+			return "", fmt.Errorf("field %s not found", path)
+		}
+		if i == len(fields)-1 {
+			if fd.IsList() || fd.IsMap() {
+				return "", fmt.Errorf("field %s is not scalar", path)
+			}
+			return formatField(fd, v.Get(fd))
+		}
+		if fd.Message() == nil || fd.Cardinality() == protoreflect.Repeated {
+			return "", fmt.Errorf("path %s is not message", field)
+		}
+		v = v.Get(fd).Message()
+	}
+	return "", errors.BadRequest("field path miss", "FIELD_PATH_MISS")
+}
+
+func encodeMessage(prefix string, v protoreflect.Message, values url.Values) error {
+	var err error
+	v.Range(func(fd protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		key := prefix + string(fd.Name())
+		switch {
+		case fd.IsList():
+			list := value.List()
+			for i := 0; i < list.Len(); i++ {
+				var s string
+				s, err = formatField(fd, list.Get(i))
+				if err != nil {
+					return false
+				}
+				values.Add(key, s)
+			}
+		case fd.IsMap():
+			value.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+				var s string
+				s, err = formatField(fd.MapValue(), mv)
+				if err != nil {
+					return false
+				}
+				values.Set(key+"."+k.String(), s)
+				return true
+			})
+		case fd.Message() != nil:
+			_, ok := msgFormatFunc[fd.Message().FullName()]
+			if !ok {
+				err = encodeMessage(key+".", value.Message(), values)
+				break
+			}
+			var s string
+			s, err = formatField(fd, value)
+			if err == nil {
+				values.Set(key, s)
+			}
+		default:
+			var s string
+			s, err = formatField(fd, value)
+			if err == nil {
+				values.Set(key, s)
+			}
+		}
+		return err == nil
+	})
+	return err
+}
+
+func formatField(fd protoreflect.FieldDescriptor, v protoreflect.Value) (string, error) {
+	if fd.Message() == nil {
+		return formatScalar(fd, v)
+	}
+	f, ok := msgFormatFunc[fd.Message().FullName()]
+	if !ok {
+		return "", fmt.Errorf("unsupported message type: %s", string(fd.Message().FullName()))
+	}
+	return f(v.Message().Interface())
+}
+
+func formatScalar(fd protoreflect.FieldDescriptor, v protoreflect.Value) (string, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return strconv.FormatBool(v.Bool()), nil
+	case protoreflect.EnumKind:
+		ev := fd.Enum().Values().ByNumber(v.Enum())
+		if ev == nil {
+			return strconv.FormatInt(int64(v.Enum()), 10), nil
+		}
+		return string(ev.Name()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind,
+		protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case protoreflect.FloatKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 32), nil
+	case protoreflect.DoubleKind:
+		return strconv.FormatFloat(v.Float(), 'g', -1, 64), nil
+	case protoreflect.StringKind:
+		return v.String(), nil
+	case protoreflect.BytesKind:
+		return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+	}
+	return "", fmt.Errorf("unknown field kind: %v", fd.Kind())
+}
+
+var msgFormatFunc = map[protoreflect.FullName]func(proto.Message) (string, error){
+	"google.protobuf.Timestamp":   formatTimestamp,
+	"google.protobuf.Duration":    formatDuration,
+	"google.protobuf.DoubleValue": formatWrapper,
+	"google.protobuf.FloatValue":  formatWrapper,
+	"google.protobuf.Int64Value":  formatWrapper,
+	"google.protobuf.Int32Value":  formatWrapper,
+	"google.protobuf.UInt64Value": formatWrapper,
+	"google.protobuf.UInt32Value": formatWrapper,
+	"google.protobuf.BoolValue":   formatWrapper,
+	"google.protobuf.StringValue": formatWrapper,
+	"google.protobuf.BytesValue":  formatWrapper,
+	"google.protobuf.FieldMask":   formatFieldMask,
+	"google.protobuf.Value":       formatJSON,
+	"google.protobuf.Struct":      formatJSON,
+}
+
+func formatTimestamp(m proto.Message) (string, error) {
+	t, ok := m.(*timestamppb.Timestamp)
+	if !ok {
+		return formatJSON(m)
+	}
+	return t.AsTime().Format(time.RFC3339Nano), nil
+}
+
+func formatDuration(m proto.Message) (string, error) {
+	d, ok := m.(*durationpb.Duration)
+	if !ok {
+		return formatJSON(m)
+	}
+	return d.AsDuration().String(), nil
+}
+
+// formatWrapper formats the value field of google.protobuf.XxxValue
+func formatWrapper(m proto.Message) (string, error) {
+	v := m.ProtoReflect()
+	fd := v.Descriptor().Fields().ByName("value")
+	return formatScalar(fd, v.Get(fd))
+}
+
+func formatFieldMask(m proto.Message) (string, error) {
+	fm, ok := m.(*fieldmaskpb.FieldMask)
+	if !ok {
+		return formatJSON(m)
+	}
+	return strings.Join(fm.Paths, ","), nil
+}
+
+func formatJSON(m proto.Message) (string, error) {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
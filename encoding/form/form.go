@@ -24,11 +24,18 @@ type codec struct {
 }
 
 func (*codec) Marshal(v interface{}) ([]byte, error) {
-	values, ok := v.(url.Values)
-	if !ok {
+	switch m := v.(type) {
+	case url.Values:
+		return []byte(m.Encode()), nil
+	case proto.Message:
+		values, err := EncodeValues(m)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(values.Encode()), nil
+	default:
 		return nil, errors.BadRequest(errors.ParamError, errors.ParamError)
 	}
-	return []byte(values.Encode()), nil
 }
 
 func (c *codec) Unmarshal(data []byte, v interface{}) error {
@@ -55,6 +55,12 @@ func (codec) Unmarshal(data []byte, v interface{}) error {
 		if m, ok := reflect.Indirect(value).Interface().(proto.Message); ok {
 			return UnmarshalOptions.Unmarshal(data, m)
 		}
+		// **T of a proto message field
+		if value.CanAddr() {
+			if m, ok := value.Addr().Interface().(proto.Message); ok {
+				return UnmarshalOptions.Unmarshal(data, m)
+			}
+		}
 		return json.Unmarshal(data, m)
 	}
 }
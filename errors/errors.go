@@ -166,14 +166,15 @@ func clone(err *Error) *Error {
 	if err.clone {
 		return err
 	}
-	err.clone = true
 	metadata := make(map[string]string, len(err.Metadata))
 	for k, v := range err.Metadata {
 		metadata[k] = v
 	}
+	// the copy is owned by the caller, so the chained With* reuse it
 	return &Error{
 		error: err.error,
 		stack: err.stack,
+		clone: true,
 		Status: Status{
 			Code:     err.Code,
 			Reason:   err.Reason,
@@ -31,3 +31,43 @@ func TestWrapError(t *testing.T) {
 func TestReWrapError(t *testing.T) {
 	fmt.Printf("%+v\n", ReWrapError())
 }
+
+func TestLocalize(t *testing.T) {
+	RegisterMessages("zh-CN", map[string]string{"USER_NOT_FOUND": "用户不存在"})
+	RegisterMessages("en", map[string]string{"USER_NOT_FOUND": "user not found"})
+	cases := []struct {
+		accept string
+		msg    string
+	}{
+		{"zh-CN,zh;q=0.9,en;q=0.8", "用户不存在"},
+		{"zh-TW", "用户不存在"},
+		{"en-US,en;q=0.9", "user not found"},
+		{"fr-FR", "not found"},
+		{"", "not found"},
+	}
+	sentinel := New(404, "not found", "USER_NOT_FOUND")
+	for _, c := range cases {
+		e := sentinel.Localize(c.accept)
+		if e.Message != c.msg {
+			t.Fatalf("accept:%s, msg:%s", c.accept, e.Message)
+		}
+	}
+	if sentinel.Message != "not found" {
+		t.Fatalf("sentinel changed:%s", sentinel.Message)
+	}
+	if !Is(sentinel.Localize("zh-CN").WithMetadata(map[string]string{"id": "1"}), sentinel) {
+		t.Fatal("localized error should match the sentinel")
+	}
+}
+
+func TestCloneSentinel(t *testing.T) {
+	sentinel := New(404, "not found", "NOT_FOUND")
+	_ = sentinel.WithMessage("first")
+	e := sentinel.WithMessage("second").WithMetadata(map[string]string{"k": "v"})
+	if sentinel.Message != "not found" || len(sentinel.Metadata) != 0 {
+		t.Fatalf("sentinel changed:%+v", sentinel)
+	}
+	if e.Message != "second" || e.Metadata["k"] != "v" {
+		t.Fatalf("error:%+v", e)
+	}
+}
@@ -0,0 +1,85 @@
+package errors
+
+import (
+	"golang.org/x/text/language"
+	"sort"
+	"sync"
+)
+
+// catalog language -> reason -> message
+var catalog = struct {
+	sync.RWMutex
+	messages map[string]map[string]string
+}{messages: map[string]map[string]string{}}
+
+// RegisterMessages registers the messages of reasons in a language such as zh-CN
+func RegisterMessages(lang string, messages map[string]string) {
+	tag, err := language.Parse(lang)
+	if err == nil {
+		lang = tag.String()
+	}
+	catalog.Lock()
+	defer catalog.Unlock()
+	mp, ok := catalog.messages[lang]
+	if !ok {
+		mp = make(map[string]string, len(messages))
+		catalog.messages[lang] = mp
+	}
+	for reason, msg := range messages {
+		mp[reason] = msg
+	}
+}
+
+// Localize returns the message of reason in the preferred language of an Accept-Language value,
+// a language such as zh-TW falls back to zh and then to another region of zh
+func Localize(reason, acceptLanguage string) (string, bool) {
+	if len(reason) == 0 || len(acceptLanguage) == 0 {
+		return "", false
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil {
+		return "", false
+	}
+	catalog.RLock()
+	defer catalog.RUnlock()
+	for _, tag := range tags {
+		msg, ok := catalog.messages[tag.String()][reason]
+		if ok {
+			return msg, true
+		}
+		base, _ := tag.Base()
+		msg, ok = catalog.messages[base.String()][reason]
+		if ok {
+			return msg, true
+		}
+		langs := make([]string, 0, len(catalog.messages))
+		for lang := range catalog.messages {
+			langs = append(langs, lang)
+		}
+		sort.Strings(langs)
+		for _, lang := range langs {
+			t, e := language.Parse(lang)
+			if e != nil {
+				continue
+			}
+			b, _ := t.Base()
+			if b != base {
+				continue
+			}
+			msg, ok = catalog.messages[lang][reason]
+			if ok {
+				return msg, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Localize returns a copy of the error with the message in the preferred language of an Accept-Language value
+func (e *Error) Localize(acceptLanguage string) *Error {
+	msg, ok := Localize(e.Reason, acceptLanguage)
+	if !ok {
+		return e
+	}
+	return e.WithMessage(msg)
+}
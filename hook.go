@@ -0,0 +1,88 @@
+package slark
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/go-slark/slark/logger"
+	"time"
+)
+
+// Hook is executed around the app lifecycle, Timeout <= 0 means no deadline
+type Hook struct {
+	Name    string
+	Timeout time.Duration
+	Fn      func(ctx context.Context) error
+}
+
+type hooks struct {
+	beforeStart []Hook
+	afterStart  []Hook
+	beforeStop  []Hook
+	afterStop   []Hook
+}
+
+func BeforeStart(hooks ...Hook) Option {
+	return func(app *App) {
+		app.hooks.beforeStart = append(app.hooks.beforeStart, hooks...)
+	}
+}
+
+func AfterStart(hooks ...Hook) Option {
+	return func(app *App) {
+		app.hooks.afterStart = append(app.hooks.afterStart, hooks...)
+	}
+}
+
+func BeforeStop(hooks ...Hook) Option {
+	return func(app *App) {
+		app.hooks.beforeStop = append(app.hooks.beforeStop, hooks...)
+	}
+}
+
+func AfterStop(hooks ...Hook) Option {
+	return func(app *App) {
+		app.hooks.afterStop = append(app.hooks.afterStop, hooks...)
+	}
+}
+
+func (h Hook) run(ctx context.Context) (err error) {
+	if h.Fn == nil {
+		return nil
+	}
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic:%v", r)
+		}
+	}()
+	return h.Fn(ctx)
+}
+
+// start runs hooks in order and aborts on the first error
+func start(ctx context.Context, hooks []Hook) error {
+	for _, h := range hooks {
+		err := h.run(ctx)
+		if err != nil {
+			return fmt.Errorf("hook %s error:%w", h.Name, err)
+		}
+	}
+	return nil
+}
+
+// stop runs all hooks in order, errors are logged and aggregated
+func stop(ctx context.Context, hooks []Hook) error {
+	var errs []error
+	for _, h := range hooks {
+		err := h.run(ctx)
+		if err != nil {
+			logger.Log(ctx, logger.ErrorLevel, map[string]interface{}{"hook": h.Name, "error": err}, "app stop hook error")
+			errs = append(errs, fmt.Errorf("hook %s error:%w", h.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
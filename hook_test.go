@@ -0,0 +1,87 @@
+package slark
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestHookStart(t *testing.T) {
+	rec := &recorder{}
+	e := errors.New("hook error")
+	err := start(context.Background(), []Hook{record(rec, "a", nil), record(rec, "b", e), record(rec, "c", nil)})
+	if !errors.Is(err, e) {
+		t.Fatalf("start error:%+v", err)
+	}
+	// aborted on the first error
+	if len(rec.events) != 2 || rec.events[1] != "b" {
+		t.Fatalf("events:%v", rec.events)
+	}
+}
+
+func TestHookStop(t *testing.T) {
+	rec := &recorder{}
+	e1, e2 := errors.New("hook error 1"), errors.New("hook error 2")
+	err := stop(context.Background(), []Hook{record(rec, "a", e1), record(rec, "b", nil), record(rec, "c", e2)})
+	// all run, the errors are aggregated
+	if !errors.Is(err, e1) || !errors.Is(err, e2) {
+		t.Fatalf("stop error:%+v", err)
+	}
+	if len(rec.events) != 3 {
+		t.Fatalf("events:%v", rec.events)
+	}
+}
+
+func TestHookRun(t *testing.T) {
+	h := Hook{
+		Name:    "timeout",
+		Timeout: 10 * time.Millisecond,
+		Fn: func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		},
+	}
+	if err := h.run(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("timeout error:%+v", err)
+	}
+	h = Hook{
+		Name: "panic",
+		Fn: func(ctx context.Context) error {
+			panic("hook panic")
+		},
+	}
+	if err := h.run(context.Background()); err == nil {
+		t.Fatal("panic not recovered")
+	}
+	if err := (Hook{Name: "nil"}).run(context.Background()); err != nil {
+		t.Fatalf("nil hook error:%+v", err)
+	}
+}
+
+func TestAppHookError(t *testing.T) {
+	rec := &recorder{}
+	e := errors.New("warmup error")
+	app := NewApp(
+		Server(newServer("srv", rec)),
+		BeforeStart(record(rec, "warmup", e)),
+	)
+	err := app.Run()
+	if !errors.Is(err, e) {
+		t.Fatalf("run error:%+v", err)
+	}
+
+	rec = &recorder{}
+	app = NewApp(
+		Server(newServer("srv", rec)),
+		AfterStart(record(rec, "preload", e)),
+		AfterStop(record(rec, "flush", e)),
+	)
+	err = app.Run()
+	if !errors.Is(err, e) {
+		t.Fatalf("run error:%+v", err)
+	}
+	if len(rec.events) != 3 || rec.events[1] != "srv stop" {
+		t.Fatalf("events:%v", rec.events)
+	}
+}
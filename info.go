@@ -0,0 +1,101 @@
+package slark
+
+import (
+	"context"
+	"runtime/debug"
+)
+
+// InstanceID env provides a stable instance id across restarts
+const InstanceID = "SLARK_INSTANCE_ID"
+
+type AppInfo interface {
+	ID() string
+	Name() string
+	Version() string
+	Hostname() string
+	Build() BuildInfo
+	Metadata() map[string]string
+	Endpoints() []string
+}
+
+// BuildInfo is read from the binary, such as the vcs revision stamped by go build
+type BuildInfo struct {
+	GoVersion string
+	Path      string // path of the main package
+	Version   string // version of the main module
+	Revision  string // vcs revision
+	Time      string // vcs commit time
+	Modified  bool   // vcs tree modified
+}
+
+func readBuildInfo() BuildInfo {
+	bi := BuildInfo{}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return bi
+	}
+	bi.GoVersion = info.GoVersion
+	bi.Path = info.Path
+	bi.Version = info.Main.Version
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			bi.Revision = setting.Value
+		case "vcs.time":
+			bi.Time = setting.Value
+		case "vcs.modified":
+			bi.Modified = setting.Value == "true"
+		}
+	}
+	return bi
+}
+
+type appKey struct{}
+
+func NewContext(ctx context.Context, info AppInfo) context.Context {
+	return context.WithValue(ctx, appKey{}, info)
+}
+
+func FromContext(ctx context.Context) (AppInfo, bool) {
+	info, ok := ctx.Value(appKey{}).(AppInfo)
+	return info, ok
+}
+
+func (a *App) ID() string {
+	return a.id
+}
+
+func (a *App) Name() string {
+	return a.name
+}
+
+func (a *App) Version() string {
+	return a.version
+}
+
+func (a *App) Hostname() string {
+	return a.hostname
+}
+
+func (a *App) Build() BuildInfo {
+	return a.build
+}
+
+func (a *App) Metadata() map[string]string {
+	return a.metadata
+}
+
+// Endpoints returns the registered endpoints after all servers are ready
+func (a *App) Endpoints() []string {
+	a.l.RLock()
+	defer a.l.RUnlock()
+	if a.svc == nil {
+		return nil
+	}
+	return a.svc.Endpoint
+}
+
+// Context carries the AppInfo, it is done after Stop is called
+func (a *App) Context() context.Context {
+	return a.ctx
+}
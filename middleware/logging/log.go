@@ -26,6 +26,24 @@ func Log(pt middleware.PeerType, l logger.Logger) middleware.Middleware {
 			}
 			kind := trans.Kind()
 			operation := trans.Operate()
+			if msg, o := middleware.MessageFromContext(ctx); o {
+				// a streamed message
+				rsp, err := handler(ctx, req)
+				fields := map[string]interface{}{
+					msg.Kind.String(): fmt.Sprintf("%+v", req),
+					"id":              msg.ID,
+					"operation":       operation,
+					"kind":            kind,
+					"type":            pt,
+				}
+				level := logger.DebugLevel
+				if err != nil {
+					fields["error"] = fmt.Errorf("%+v", err)
+					level = logger.ErrorLevel
+				}
+				l.Log(ctx, level, fields, "stream message log")
+				return rsp, err
+			}
 			start := time.Now()
 			fields := map[string]interface{}{
 				"request":   fmt.Sprintf("%+v", req),
@@ -7,22 +7,19 @@ import (
 	"github.com/go-slark/slark/transport"
 )
 
+// Metadata propagates the x-md- headers such as x-md-color, the server keeps them of the inbound request in the context,
+// and the client carries them of the context to the outbound request unless set already
 func Metadata(pt middleware.PeerType) middleware.Middleware {
 	w := metadata.New()
 	return func(handler middleware.Handler) middleware.Handler {
 		return func(ctx context.Context, req interface{}) (interface{}, error) {
-			var (
-				ok    bool
-				trans transport.Transporter
-				md    metadata.Metadata
-			)
-			carrier := trans.ReqCarrier()
 			if pt == middleware.Server {
-				trans, ok = transport.FromServerContext(ctx)
+				trans, ok := transport.FromServerContext(ctx)
 				if !ok {
 					return handler(ctx, req)
 				}
-				md = metadata.Metadata{}
+				carrier := trans.ReqCarrier()
+				md := metadata.Metadata{}
 				for _, key := range carrier.Keys() {
 					if !w.HasPrefix(key) {
 						continue
@@ -33,19 +30,20 @@ func Metadata(pt middleware.PeerType) middleware.Middleware {
 				}
 				ctx = metadata.NewMetadataContext(ctx, md)
 			} else if pt == middleware.Client {
-				trans, ok = transport.FromClientContext(ctx)
+				trans, ok := transport.FromClientContext(ctx)
 				if !ok {
 					return handler(ctx, req)
 				}
-				md, ok = metadata.FromMetadataContext(ctx)
+				md, ok := metadata.FromMetadataContext(ctx)
 				if !ok {
 					return handler(ctx, req)
 				}
-				for _, key := range carrier.Keys() {
-					if !w.HasPrefix(key) {
+				carrier := trans.ReqCarrier()
+				for key, values := range md {
+					if !w.HasPrefix(key) || len(carrier.Get(key)) > 0 {
 						continue
 					}
-					for _, value := range carrier.Values(key) {
+					for _, value := range values {
 						carrier.Add(key, value)
 					}
 				}
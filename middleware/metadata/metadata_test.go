@@ -0,0 +1,59 @@
+package metadata
+
+import (
+	"context"
+	"github.com/go-slark/slark/middleware"
+	"github.com/go-slark/slark/pkg/metadata"
+	"github.com/go-slark/slark/transport"
+	"net/http"
+	"testing"
+)
+
+type carrier http.Header
+
+func (c carrier) Set(k, v string)          { http.Header(c).Set(k, v) }
+func (c carrier) Add(k, v string)          { http.Header(c).Add(k, v) }
+func (c carrier) Get(k string) string      { return http.Header(c).Get(k) }
+func (c carrier) Values(k string) []string { return http.Header(c).Values(k) }
+func (c carrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+type mockTransport struct {
+	req carrier
+}
+
+func (t *mockTransport) Kind() string                  { return transport.HTTP }
+func (t *mockTransport) Operate() string               { return "/test" }
+func (t *mockTransport) ReqCarrier() transport.Carrier { return t.req }
+func (t *mockTransport) RspCarrier() transport.Carrier { return carrier{} }
+
+func TestMetadata(t *testing.T) {
+	in := &mockTransport{req: carrier{}}
+	in.req.Set("X-Md-Color", "blue")
+	in.req.Set("X-Md-Mirror", "true")
+	in.req.Set("Authorization", "token")
+	out := &mockTransport{req: carrier{}}
+	out.req.Set("X-Md-Mirror", "false")
+
+	client := Metadata(middleware.Client)(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	server := Metadata(middleware.Server)(func(ctx context.Context, req interface{}) (interface{}, error) {
+		md, ok := metadata.FromMetadataContext(ctx)
+		if !ok || len(md[metadata.Color]) == 0 || md[metadata.Color][0] != "blue" || len(md["authorization"]) > 0 {
+			t.Fatalf("inbound metadata:%+v", md)
+		}
+		return client(transport.NewClientContext(ctx, out), req)
+	})
+	if _, err := server(transport.NewServerContext(context.Background(), in), nil); err != nil {
+		t.Fatal(err)
+	}
+	if out.req.Get(metadata.Color) != "blue" || out.req.Get("X-Md-Mirror") != "false" || len(out.req.Get("Authorization")) > 0 {
+		t.Fatalf("outbound metadata:%+v", out.req)
+	}
+}
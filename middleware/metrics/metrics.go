@@ -31,6 +31,23 @@ func Metrics(pt middleware.PeerType, opts ...metric.Option) middleware.Middlewar
 			}
 			kind = trans.Kind()
 			operation = trans.Operate()
+			if msg, o := middleware.MessageFromContext(ctx); o {
+				// a streamed message is counted, the duration is of the whole stream
+				rsp, err := handler(ctx, req)
+				if err != nil {
+					e := errors.FromError(err)
+					reason = e.Reason
+					code = e.Code
+				}
+				meter.Counter(ctx,
+					attribute.String("kind", kind),
+					attribute.String("operation", operation),
+					attribute.String("message", msg.Kind.String()),
+					attribute.String("code", strconv.Itoa(int(code))),
+					attribute.String("reason", reason),
+				)
+				return rsp, err
+			}
 			start := time.Now()
 			rsp, err := handler(ctx, req)
 			if err != nil {
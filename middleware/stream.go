@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"context"
+)
+
+// MessageKind is the kind of a streamed message passed through the stream middlewares
+type MessageKind int
+
+const (
+	Request MessageKind = iota + 1
+	Reply
+)
+
+func (k MessageKind) String() string {
+	switch k {
+	case Request:
+		return "request"
+	case Reply:
+		return "reply"
+	}
+	return "unknown"
+}
+
+// Message describes a streamed message, the id is counted from 1 per kind of a stream
+type Message struct {
+	Kind MessageKind
+	ID   int
+}
+
+type messageContextKey struct{}
+
+// NewMessageContext marks ctx as the context of a streamed message, so that the middlewares observe the message
+// instead of a whole call, the request is received by the server and sent by the client, the reply is the opposite
+func NewMessageContext(ctx context.Context, msg *Message) context.Context {
+	return context.WithValue(ctx, messageContextKey{}, msg)
+}
+
+func MessageFromContext(ctx context.Context) (*Message, bool) {
+	msg, ok := ctx.Value(messageContextKey{}).(*Message)
+	return msg, ok
+}
+
+// Stream is the request of a whole stream passed through the middlewares, the streamed messages are passed through the stream middlewares
+type Stream struct {
+	ClientStreams bool
+	ServerStreams bool
+}
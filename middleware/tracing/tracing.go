@@ -31,6 +31,15 @@ func Trace(kind trace.SpanKind, opts ...tracing.Option) middleware.Middleware {
 				return handler(ctx, req)
 			}
 
+			if msg, o := middleware.MessageFromContext(ctx); o {
+				// a streamed message is an event of the span of the stream
+				event := tracing.MessageReceived
+				if (msg.Kind == middleware.Request) == (kind == trace.SpanKindClient) {
+					event = tracing.MessageSent
+				}
+				event.Event(ctx, msg.ID, req)
+				return handler(ctx, req)
+			}
 			operation := trans.Operate()
 			k := trans.Kind()
 			var attrs []attribute.KeyValue
@@ -30,6 +30,9 @@ func init() {
 func Validate() middleware.Middleware {
 	return func(handler middleware.Handler) middleware.Handler {
 		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			if msg, o := middleware.MessageFromContext(ctx); o && msg.Kind != middleware.Request {
+				return handler(ctx, req)
+			}
 			validator, ok := req.(Validator)
 			if ok {
 				err := validator.ValidateAll()
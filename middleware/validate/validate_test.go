@@ -3,6 +3,7 @@ package validate
 import (
 	"context"
 	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/middleware"
 	"testing"
 )
 
@@ -29,3 +30,17 @@ func TestValidate(t *testing.T) {
 	})(context.TODO(), 1)
 	t.Log(err)
 }
+
+func TestValidateStream(t *testing.T) {
+	handler := Validate()(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, nil
+	})
+	_, err := handler(middleware.NewMessageContext(context.TODO(), &middleware.Message{Kind: middleware.Request, ID: 1}), &validator{})
+	if err == nil {
+		t.Fatal("streamed request not validated")
+	}
+	_, err = handler(middleware.NewMessageContext(context.TODO(), &middleware.Message{Kind: middleware.Reply, ID: 1}), &validator{})
+	if err != nil {
+		t.Fatalf("streamed reply validated:%v", err)
+	}
+}
@@ -100,6 +100,13 @@ func (c *Consistent) Delete(node string) {
 }
 
 func (c *Consistent) Fetch(node string) string {
+	return c.FetchFunc(node, func(string) bool {
+		return true
+	})
+}
+
+// FetchFunc 顺时针查找首个fn接受的真实节点, 节点不可用时回退到环上的下一个节点
+func (c *Consistent) FetchFunc(node string, fn func(node string) bool) string {
 	c.l.RLock()
 	defer c.l.RUnlock()
 	if len(c.ring) == 0 {
@@ -112,16 +119,17 @@ func (c *Consistent) Fetch(node string) string {
 	index := sort.Search(size, func(i int) bool {
 		return c.vnl[i] >= h
 	}) % size
-	nodes := c.ring[c.vnl[index]]
-	size = len(nodes)
-	if size == 0 {
-		return ""
-	} else if size == 1 {
-		return nodes[0]
-	} else {
-		// 冲突:一个虚拟节点对应多个真实节点，再hash并对真实节点取模
-		// 32 bit FNV_prime取值 = 2^24 + 2^8 + 0x93 = 16777619 / FNV保持较小冲突概率
-		h = c.f([]byte(node + "-" + strconv.Itoa(16777619)))
-		return nodes[h%uint64(len(nodes))]
+	// 冲突:一个虚拟节点对应多个真实节点，再hash并对真实节点取模
+	// 32 bit FNV_prime取值 = 2^24 + 2^8 + 0x93 = 16777619 / FNV保持较小冲突概率
+	offset := c.f([]byte(node + "-" + strconv.Itoa(16777619)))
+	for i := 0; i < size; i++ {
+		nodes := c.ring[c.vnl[(index+i)%size]]
+		for j := range nodes {
+			n := nodes[(offset+uint64(j))%uint64(len(nodes))]
+			if fn(n) {
+				return n
+			}
+		}
 	}
+	return ""
 }
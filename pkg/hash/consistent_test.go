@@ -1,6 +1,7 @@
 package hash
 
 import (
+	"strconv"
 	"testing"
 )
 
@@ -13,3 +14,30 @@ func TestConsistent(t *testing.T) {
 	node := h.Fetch("125.0.0.1" + "0")
 	t.Logf("node:%s", node)
 }
+
+func TestFetchFunc(t *testing.T) {
+	h := New()
+	nodes := []string{"127.0.0.1", "126.0.0.1", "125.0.0.1"}
+	for _, node := range nodes {
+		h.Add(node)
+	}
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		node := h.Fetch(key)
+		fallback := h.FetchFunc(key, func(n string) bool {
+			return n != node
+		})
+		if len(fallback) == 0 || fallback == node {
+			t.Fatalf("key:%s, node:%s, fallback:%s", key, node, fallback)
+		}
+		// the fallback is the node taking over the key once the node is deleted
+		h.Delete(node)
+		if h.Fetch(key) != fallback {
+			t.Fatalf("key:%s, fallback:%s, fetch:%s", key, fallback, h.Fetch(key))
+		}
+		h.Add(node)
+	}
+	if h.FetchFunc("0", func(string) bool { return false }) != "" {
+		t.Fatal("no node accepted")
+	}
+}
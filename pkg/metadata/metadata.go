@@ -11,7 +11,11 @@ import (
 	x-md-probe
 */
 
-const Prefix = "x-md-"
+const (
+	Prefix = "x-md-"
+	// Color :the lane of the request, routed to the nodes of the same color by filter.Color
+	Color = Prefix + "color"
+)
 
 type Metadata map[string][]string
 
@@ -28,14 +28,20 @@ const (
 	XForwardedMethod = "X-Forwarded-Method"
 	XForwardedURI    = "X-Forwarded-Uri"
 	XForwardedIP     = "X-Forwarded-For"
+	XEnvelope        = "X-Envelope"
 
-	ContentType = "Content-Type"
-	Accept      = "Accept"
-	Application = "application"
+	ContentType    = "Content-Type"
+	Accept         = "Accept"
+	AcceptLanguage = "Accept-Language"
+	Application    = "application"
 
 	Discovery       = "discovery"
 	Weight          = "weight"
+	Zone            = "zone"
+	Region          = "region"
+	Color           = "color"
 	ServiceRegistry = "service-registry"
+	CPUUsage        = "x-cpu-usage"
 )
 
 func BuildRequestID() string {
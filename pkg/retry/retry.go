@@ -25,7 +25,6 @@ type Option struct {
 	maxJitter time.Duration
 	f         Func
 	timer     func(time.Duration) <-chan time.Time
-	ctx       context.Context
 	debug     bool
 }
 
@@ -35,7 +34,6 @@ func NewOption(opts ...Opt) *Option {
 		delay:     100 * time.Millisecond,
 		maxJitter: 100 * time.Millisecond,
 		f:         BackOff,
-		ctx:       context.TODO(),
 		timer: func(d time.Duration) <-chan time.Time {
 			timer := time.NewTimer(d)
 			defer timer.Stop()
@@ -87,12 +85,6 @@ func Function(f Func) Opt {
 	}
 }
 
-func Context(ctx context.Context) Opt {
-	return func(o *Option) {
-		o.ctx = ctx
-	}
-}
-
 func Debug(debug bool) Opt {
 	return func(o *Option) {
 		o.debug = debug
@@ -0,0 +1,264 @@
+package consul
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-slark/slark/logger"
+	"github.com/go-slark/slark/registry"
+	"github.com/hashicorp/consul/api"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const version = "version"
+
+var agentTagged = map[string]struct{}{
+	"lan_ipv4": {},
+	"wan_ipv4": {},
+	"lan_ipv6": {},
+	"wan_ipv6": {},
+}
+
+type Registry struct {
+	client  *api.Client
+	opt     *option
+	l       sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func NewRegistry(cfg *api.Config, opts ...Option) (*Registry, error) {
+	opt := &option{
+		ttl:        10 * time.Second,
+		deregister: time.Minute,
+		wait:       55 * time.Second,
+		healthy:    true,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	client, err := api.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Registry{
+		client:  client,
+		opt:     opt,
+		cancels: map[string]context.CancelFunc{},
+	}, nil
+}
+
+func checkID(id string) string {
+	return "service:" + id
+}
+
+func (r *Registry) Register(ctx context.Context, svc *registry.Service) error {
+	tagged := make(map[string]api.ServiceAddress, len(svc.Endpoint))
+	var (
+		addr string
+		port int
+	)
+	for _, endpoint := range svc.Endpoint {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		p, err := strconv.Atoi(u.Port())
+		if err != nil {
+			return err
+		}
+		if len(addr) == 0 {
+			addr, port = u.Hostname(), p
+		}
+		// the endpoints of the same scheme are tagged as scheme, scheme_1, scheme_2...
+		key := u.Scheme
+		for i := 1; ; i++ {
+			_, ok := tagged[key]
+			if !ok {
+				break
+			}
+			key = fmt.Sprintf("%s_%d", u.Scheme, i)
+		}
+		tagged[key] = api.ServiceAddress{Address: u.Hostname(), Port: p}
+	}
+	md := make(map[string]string, len(svc.Metadata)+1)
+	for k, v := range svc.Metadata {
+		md[k] = v
+	}
+	md[version] = svc.Version
+	reg := &api.AgentServiceRegistration{
+		ID:              svc.ID,
+		Name:            svc.Name,
+		Address:         addr,
+		Port:            port,
+		TaggedAddresses: tagged,
+		Meta:            md,
+		Checks: api.AgentServiceChecks{
+			{
+				CheckID:                        checkID(svc.ID),
+				TTL:                            r.opt.ttl.String(),
+				DeregisterCriticalServiceAfter: r.opt.deregister.String(),
+			},
+		},
+	}
+	err := r.client.Agent().ServiceRegisterOpts(reg, api.ServiceRegisterOpts{ReplaceExistingChecks: true}.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	err = r.client.Agent().UpdateTTLOpts(checkID(svc.ID), "", api.HealthPassing, (&api.QueryOptions{}).WithContext(ctx))
+	if err != nil {
+		return err
+	}
+
+	cx, cancel := context.WithCancel(context.Background())
+	r.l.Lock()
+	c, ok := r.cancels[svc.ID]
+	if ok {
+		c()
+	}
+	r.cancels[svc.ID] = cancel
+	r.l.Unlock()
+	go r.heartbeat(cx, svc.ID)
+	return nil
+}
+
+func (r *Registry) heartbeat(ctx context.Context, id string) {
+	ticker := time.NewTicker(r.opt.ttl / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := r.client.Agent().UpdateTTLOpts(checkID(id), "", api.HealthPassing, (&api.QueryOptions{}).WithContext(ctx))
+			if err != nil && ctx.Err() == nil {
+				logger.Log(ctx, logger.ErrorLevel, map[string]interface{}{"id": id, "error": err}, "consul heartbeat error")
+			}
+		}
+	}
+}
+
+func (r *Registry) Unregister(ctx context.Context, svc *registry.Service) error {
+	r.l.Lock()
+	cancel, ok := r.cancels[svc.ID]
+	if ok {
+		cancel()
+		delete(r.cancels, svc.ID)
+	}
+	r.l.Unlock()
+	return r.client.Agent().ServiceDeregisterOpts(svc.ID, (&api.QueryOptions{}).WithContext(ctx))
+}
+
+func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher, error) {
+	w := &watcher{
+		client:  r.client,
+		name:    name,
+		wait:    r.opt.wait,
+		healthy: r.opt.healthy,
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	return w, nil
+}
+
+type watcher struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	client   *api.Client
+	name     string
+	wait     time.Duration
+	healthy  bool
+	index    uint64
+	snapshot registry.Snapshot
+}
+
+func (w *watcher) Next(ctx context.Context) (*registry.Event, error) {
+	for {
+		svc, err := w.service(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e, ok := w.snapshot.Update(svc)
+		if ok {
+			return e, nil
+		}
+	}
+}
+
+// service blocks until the index changes except the first query
+func (w *watcher) service(ctx context.Context) ([]*registry.Service, error) {
+	cx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-w.ctx.Done():
+			cancel()
+		case <-cx.Done():
+		}
+	}()
+	q := &api.QueryOptions{WaitIndex: w.index, WaitTime: w.wait}
+	entries, meta, err := w.client.Health().Service(w.name, "", w.healthy, q.WithContext(cx))
+	if w.ctx.Err() != nil {
+		return nil, registry.ErrWatcherStopped
+	}
+	if err != nil {
+		return nil, err
+	}
+	// reset the index if it goes backwards
+	if meta.LastIndex < w.index {
+		w.index = 0
+	} else {
+		w.index = meta.LastIndex
+	}
+
+	svc := make([]*registry.Service, 0, len(entries))
+	for _, entry := range entries {
+		s := &registry.Service{
+			ID:       entry.Service.ID,
+			Name:     entry.Service.Service,
+			Version:  entry.Service.Meta[version],
+			Metadata: make(map[string]string, len(entry.Service.Meta)),
+		}
+		for k, v := range entry.Service.Meta {
+			if k != version {
+				s.Metadata[k] = v
+			}
+		}
+		for key, addr := range entry.Service.TaggedAddresses {
+			// skip the addresses tagged by consul agent
+			_, ok := agentTagged[key]
+			if ok {
+				continue
+			}
+			u := &url.URL{
+				Scheme: scheme(key),
+				Host:   net.JoinHostPort(addr.Address, fmt.Sprintf("%d", addr.Port)),
+			}
+			s.Endpoint = append(s.Endpoint, u.String())
+		}
+		sort.Strings(s.Endpoint)
+		svc = append(svc, s)
+	}
+	return svc, nil
+}
+
+// scheme trims the index suffix of the tagged key, the scheme contains no underscore
+func scheme(key string) string {
+	i := strings.LastIndexByte(key, '_')
+	if i < 0 {
+		return key
+	}
+	_, err := strconv.Atoi(key[i+1:])
+	if err != nil {
+		return key
+	}
+	return key[:i]
+}
+
+func (w *watcher) Stop() error {
+	w.cancel()
+	return nil
+}
@@ -0,0 +1,147 @@
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-slark/slark/registry"
+	"github.com/hashicorp/consul/api"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// agent is an in-process fake of the consul agent / health http api
+type agent struct {
+	l        sync.Mutex
+	cond     *sync.Cond
+	index    uint64
+	services map[string]*api.AgentServiceRegistration
+	passing  map[string]bool
+}
+
+func newAgent() *agent {
+	a := &agent{services: map[string]*api.AgentServiceRegistration{}, passing: map[string]bool{}, index: 1}
+	a.cond = sync.NewCond(&a.l)
+	return a
+}
+
+func (a *agent) change() {
+	a.index++
+	a.cond.Broadcast()
+}
+
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.l.Lock()
+	defer a.l.Unlock()
+	switch {
+	case r.URL.Path == "/v1/agent/service/register":
+		reg := &api.AgentServiceRegistration{}
+		_ = json.NewDecoder(r.Body).Decode(reg)
+		a.services[reg.ID] = reg
+		a.change()
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/service/deregister/"):
+		delete(a.services, strings.TrimPrefix(r.URL.Path, "/v1/agent/service/deregister/"))
+		a.change()
+	case strings.HasPrefix(r.URL.Path, "/v1/agent/check/update/"):
+		id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/v1/agent/check/update/"), "service:")
+		if !a.passing[id] {
+			a.passing[id] = true
+			a.change()
+		}
+	case strings.HasPrefix(r.URL.Path, "/v1/health/service/"):
+		name := strings.TrimPrefix(r.URL.Path, "/v1/health/service/")
+		index, _ := strconv.ParseUint(r.URL.Query().Get("index"), 10, 64)
+		deadline := time.Now().Add(time.Second)
+		for index >= a.index && time.Now().Before(deadline) {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				a.cond.Broadcast()
+			}()
+			a.cond.Wait()
+		}
+		entries := make([]*api.ServiceEntry, 0)
+		for id, reg := range a.services {
+			if reg.Name != name || (r.URL.Query().Has("passing") && !a.passing[id]) {
+				continue
+			}
+			tagged := map[string]api.ServiceAddress{"lan_ipv4": {Address: reg.Address, Port: reg.Port}}
+			for k, v := range reg.TaggedAddresses {
+				tagged[k] = v
+			}
+			entries = append(entries, &api.ServiceEntry{Service: &api.AgentService{
+				ID:              reg.ID,
+				Service:         reg.Name,
+				Meta:            reg.Meta,
+				Address:         reg.Address,
+				Port:            reg.Port,
+				TaggedAddresses: tagged,
+			}})
+		}
+		w.Header().Set("X-Consul-Index", strconv.FormatUint(a.index, 10))
+		_ = json.NewEncoder(w).Encode(entries)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestConsulRegistry(t *testing.T) {
+	srv := httptest.NewServer(newAgent())
+	defer srv.Close()
+	r, err := NewRegistry(&api.Config{Address: strings.TrimPrefix(srv.URL, "http://")}, TTL(time.Second), WaitTime(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	w, err := r.Discover(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	e, err := w.Next(ctx)
+	if err != nil || len(e.Services) != 0 {
+		t.Fatalf("initial snapshot:%+v, error:%+v", e, err)
+	}
+
+	svc := &registry.Service{
+		ID:       "1",
+		Name:     "test",
+		Version:  "v1",
+		Endpoint: []string{"grpc://127.0.0.1:9090", "grpc://127.0.0.1:9091", "http://127.0.0.1:8080"},
+		Metadata: map[string]string{"weight": "50", "region": "sh"},
+	}
+	err = r.Register(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Added) != 1 {
+		t.Fatalf("register event:%+v, error:%+v", e, err)
+	}
+	s := e.Added[0]
+	if s.Version != "v1" || s.Metadata["weight"] != "50" || s.Metadata["region"] != "sh" || !reflect.DeepEqual(s.Endpoint, svc.Endpoint) {
+		t.Fatalf("service:%+v", s)
+	}
+
+	err = r.Unregister(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Removed) != 1 {
+		t.Fatalf("unregister event:%+v, error:%+v", e, err)
+	}
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = w.Stop()
+	}()
+	_, err = w.Next(ctx)
+	if err != registry.ErrWatcherStopped {
+		t.Fatalf("stop error:%+v", err)
+	}
+}
@@ -0,0 +1,40 @@
+package consul
+
+import "time"
+
+type option struct {
+	ttl        time.Duration
+	deregister time.Duration
+	wait       time.Duration
+	healthy    bool
+}
+
+type Option func(*option)
+
+// TTL :the service is critical if no heartbeat within ttl
+func TTL(ttl time.Duration) Option {
+	return func(o *option) {
+		o.ttl = ttl
+	}
+}
+
+// DeregisterAfter :the critical service is deregistered by consul after d
+func DeregisterAfter(d time.Duration) Option {
+	return func(o *option) {
+		o.deregister = d
+	}
+}
+
+// WaitTime :max blocking time of the watch query
+func WaitTime(wait time.Duration) Option {
+	return func(o *option) {
+		o.wait = wait
+	}
+}
+
+// HealthyOnly :discover passing services only
+func HealthyOnly(healthy bool) Option {
+	return func(o *option) {
+		o.healthy = healthy
+	}
+}
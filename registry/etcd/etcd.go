@@ -8,17 +8,29 @@ import (
 	"github.com/go-slark/slark/pkg/retry"
 	"github.com/go-slark/slark/registry"
 	"go.etcd.io/etcd/client/v3"
+	"sync"
 	"time"
 )
 
 type Registry struct {
-	client *clientv3.Client
+	client   *clientv3.Client
+	kv       clientv3.KV
+	newLease func() clientv3.Lease
+	opt      *option
+	l        sync.Mutex
+	leases   map[string]*lease
+}
+
+// lease of a registered service key, ul guards the id and the value, and serializes their puts
+type lease struct {
 	lease  clientv3.Lease
-	kv     clientv3.KV
-	opt    *option
+	id     clientv3.LeaseID
+	value  string
+	cancel context.CancelFunc
+	ul     sync.Mutex
 }
 
-func NewRegistry(cfg clientv3.Config, opts ...Option) *Registry {
+func NewRegistry(cfg clientv3.Config, opts ...Option) (*Registry, error) {
 	opt := &option{
 		ctx:   context.Background(),
 		ns:    "/default",
@@ -31,45 +43,95 @@ func NewRegistry(cfg clientv3.Config, opts ...Option) *Registry {
 
 	client, err := clientv3.New(cfg)
 	if err != nil {
-		panic(fmt.Sprintf("etcd registry error:%+v", err))
+		return nil, err
 	}
 	return &Registry{
 		client: client,
 		kv:     clientv3.NewKV(client),
+		newLease: func() clientv3.Lease {
+			return clientv3.NewLease(client)
+		},
 		opt:    opt,
-	}
+		leases: map[string]*lease{},
+	}, nil
+}
+
+func (r *Registry) key(svc *registry.Service) string {
+	return fmt.Sprintf("%s/%s/%s", r.opt.ns, svc.Name, svc.ID)
 }
 
+// Register puts the service with its own lease, registering the same service again replaces the previous lease
 func (r *Registry) Register(ctx context.Context, svc *registry.Service) error {
-	key := fmt.Sprintf("%s/%s/%s", r.opt.ns, svc.Name, svc.ID)
-	value, _ := json.Marshal(svc)
-	if r.lease != nil {
+	key := r.key(svc)
+	value, err := json.Marshal(svc)
+	if err != nil {
+		return err
+	}
+	l := &lease{
+		lease: r.newLease(),
+		value: string(value),
+	}
+	l.id, err = r.put(ctx, l.lease, key, l.value)
+	if err != nil {
+		_ = l.lease.Close()
+		return err
+	}
+	cx, cancel := context.WithCancel(r.opt.ctx)
+	l.cancel = cancel
+
+	r.l.Lock()
+	old, ok := r.leases[key]
+	r.leases[key] = l
+	r.l.Unlock()
+	if ok {
 		// release lease resource
-		_ = r.lease.Close()
+		old.cancel()
+		_ = old.lease.Close()
 	}
-	r.lease = clientv3.NewLease(r.client)
-	leaseID, err := r.put(ctx, key, string(value))
+	go r.keepAlive(cx, l, key)
+	return nil
+}
+
+// Update changes the registered service value such as metadata without re-registering
+func (r *Registry) Update(ctx context.Context, svc *registry.Service) error {
+	key := r.key(svc)
+	value, err := json.Marshal(svc)
 	if err != nil {
 		return err
 	}
-	go r.keepAlive(r.opt.ctx, leaseID, key, string(value))
+	r.l.Lock()
+	l, ok := r.leases[key]
+	r.l.Unlock()
+	if !ok {
+		return errors.BadRequest("service not registered", "SERVICE_NOT_REGISTERED")
+	}
+	l.ul.Lock()
+	defer l.ul.Unlock()
+	_, err = r.kv.Put(ctx, key, string(value), clientv3.WithLease(l.id))
+	if err != nil {
+		return err
+	}
+	l.value = string(value)
 	return nil
 }
 
-func (r *Registry) put(ctx context.Context, key, value string) (clientv3.LeaseID, error) {
-	grant, err := r.lease.Grant(ctx, r.opt.ttl)
+func (r *Registry) put(ctx context.Context, lease clientv3.Lease, key, value string) (clientv3.LeaseID, error) {
+	grant, err := lease.Grant(ctx, r.opt.ttl)
 	if err != nil {
 		return 0, err
 	}
-	_, err = r.client.Put(ctx, key, value, clientv3.WithLease(grant.ID))
+	_, err = r.kv.Put(ctx, key, value, clientv3.WithLease(grant.ID))
 	if err != nil {
 		return 0, err
 	}
 	return grant.ID, nil
 }
 
-func (r *Registry) keepAlive(ctx context.Context, leaseID clientv3.LeaseID, key, value string) {
-	ch, err := r.client.KeepAlive(ctx, leaseID)
+func (r *Registry) keepAlive(ctx context.Context, l *lease, key string) {
+	l.ul.Lock()
+	leaseID := l.id
+	l.ul.Unlock()
+	ch, err := l.lease.KeepAlive(ctx, leaseID)
 	if err != nil {
 		leaseID = 0
 	}
@@ -83,29 +145,20 @@ func (r *Registry) keepAlive(ctx context.Context, leaseID clientv3.LeaseID, key,
 					return e
 				}
 
-				// non-blocking
-				ic := make(chan clientv3.LeaseID, 1)
-				ec := make(chan error, 1)
+				// a concurrent update is not overwritten by the stale value put again
+				l.ul.Lock()
 				cx, cancel := context.WithTimeout(ctx, 3*time.Second)
-				go func() {
-					defer cancel()
-					id, pe := r.put(cx, key, value)
-					if pe != nil {
-						ec <- pe
-					} else {
-						ic <- id
-					}
-				}()
-
-				select {
-				case <-cx.Done():
-					return errors.InternalServer("time out", "TIME_OUT")
-				case e = <-ec:
+				id, e := r.put(cx, l.lease, key, l.value)
+				cancel()
+				if e == nil {
+					l.id = id
+				}
+				l.ul.Unlock()
+				if e != nil {
 					return e
-				case leaseID = <-ic:
 				}
-
-				ch, err = r.client.KeepAlive(ctx, leaseID)
+				leaseID = id
+				ch, err = l.lease.KeepAlive(ctx, leaseID)
 				return err
 			})
 			if err != nil {
@@ -133,11 +186,24 @@ func (r *Registry) keepAlive(ctx context.Context, leaseID clientv3.LeaseID, key,
 	}
 }
 
+// Unregister stops the keepalive and revokes the lease of the service
 func (r *Registry) Unregister(ctx context.Context, svc *registry.Service) error {
-	key := fmt.Sprintf("%s/%s/%s", r.opt.ns, svc.Name, svc.ID)
-	_, err := r.client.Delete(ctx, key)
-	if r.lease != nil {
-		_ = r.lease.Close()
+	key := r.key(svc)
+	r.l.Lock()
+	l, ok := r.leases[key]
+	delete(r.leases, key)
+	r.l.Unlock()
+	var leaseID clientv3.LeaseID
+	if ok {
+		l.cancel()
+		l.ul.Lock()
+		leaseID = l.id
+		l.ul.Unlock()
+	}
+	_, err := r.kv.Delete(ctx, key)
+	if ok {
+		_, _ = l.lease.Revoke(ctx, leaseID)
+		_ = l.lease.Close()
 	}
 	return err
 }
@@ -166,12 +232,14 @@ func (r *Registry) Service(ctx context.Context, name string) ([]*registry.Servic
 func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher, error) {
 	key := fmt.Sprintf("%s/%s", r.opt.ns, name)
 	w := &watcher{
-		key:     key,
-		client:  r.client,
-		watcher: clientv3.NewWatcher(r.client),
-		kv:      clientv3.NewKV(r.client),
-		name:    name,
+		key: key,
+		newWatcher: func() clientv3.Watcher {
+			return clientv3.NewWatcher(r.client)
+		},
+		kv:   clientv3.NewKV(r.client),
+		name: name,
 	}
+	w.watcher = w.newWatcher()
 	w.ctx, w.cancel = context.WithCancel(ctx)
 	w.wc = w.watcher.Watch(w.ctx, key, clientv3.WithPrefix(), clientv3.WithRev(0), clientv3.WithKeysOnly())
 	err := w.watcher.RequestProgress(w.ctx)
@@ -182,38 +250,71 @@ func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher,
 }
 
 type watcher struct {
-	key     string
-	ctx     context.Context
-	cancel  context.CancelFunc
-	client  *clientv3.Client
-	wc      clientv3.WatchChan
-	watcher clientv3.Watcher
-	kv      clientv3.KV
-	name    string
+	key        string
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wc         clientv3.WatchChan
+	newWatcher func() clientv3.Watcher
+	watcher    clientv3.Watcher
+	kv         clientv3.KV
+	name       string
+	snapshot   registry.Snapshot
+	l          sync.Mutex // guards watcher against Stop
+	stopped    bool
 }
 
-func (w *watcher) List() ([]*registry.Service, error) {
+func (w *watcher) Next(ctx context.Context) (*registry.Event, error) {
+	for {
+		if w.snapshot.Initialized() {
+			err := w.wait(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		svc, err := w.getService(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e, ok := w.snapshot.Update(svc)
+		if ok {
+			return e, nil
+		}
+	}
+}
+
+func (w *watcher) wait(ctx context.Context) error {
 	select {
+	case <-ctx.Done():
+		return ctx.Err()
+
 	case <-w.ctx.Done():
-		return nil, w.ctx.Err()
+		return registry.ErrWatcherStopped
 
 	case rsp, ok := <-w.wc:
-		if !ok || rsp.Err() != nil {
-			time.Sleep(time.Second)
-			_ = w.watcher.Close()
-			w.watcher = clientv3.NewWatcher(w.client)
-			w.wc = w.watcher.Watch(w.ctx, w.key, clientv3.WithPrefix(), clientv3.WithRev(0), clientv3.WithKeysOnly())
-			err := w.watcher.RequestProgress(w.ctx)
-			if err != nil {
-				return nil, err
-			}
+		if ok && rsp.Err() == nil {
+			return nil
 		}
-		return w.getService()
+		select {
+		case <-time.After(time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-w.ctx.Done():
+			return registry.ErrWatcherStopped
+		}
+		w.l.Lock()
+		defer w.l.Unlock()
+		if w.stopped {
+			return registry.ErrWatcherStopped
+		}
+		_ = w.watcher.Close()
+		w.watcher = w.newWatcher()
+		w.wc = w.watcher.Watch(w.ctx, w.key, clientv3.WithPrefix(), clientv3.WithRev(0), clientv3.WithKeysOnly())
+		return w.watcher.RequestProgress(w.ctx)
 	}
 }
 
-func (w *watcher) getService() ([]*registry.Service, error) {
-	rsp, err := w.kv.Get(w.ctx, w.key, clientv3.WithPrefix())
+func (w *watcher) getService(ctx context.Context) ([]*registry.Service, error) {
+	rsp, err := w.kv.Get(ctx, w.key, clientv3.WithPrefix())
 	if err != nil {
 		return nil, err
 	}
@@ -234,6 +335,12 @@ func (w *watcher) getService() ([]*registry.Service, error) {
 }
 
 func (w *watcher) Stop() error {
+	w.l.Lock()
+	defer w.l.Unlock()
+	if w.stopped {
+		return nil
+	}
+	w.stopped = true
 	w.cancel()
 	return w.watcher.Close()
 }
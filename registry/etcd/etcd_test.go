@@ -0,0 +1,296 @@
+package etcd
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-slark/slark/registry"
+	"go.etcd.io/etcd/client/v3"
+	"sync"
+	"testing"
+	"time"
+)
+
+// kv is an in-process fake of the etcd kv
+type kv struct {
+	clientv3.KV
+	l      sync.Mutex
+	values map[string]string
+	put    chan struct{}
+	block  chan struct{}
+}
+
+func (k *kv) Put(ctx context.Context, key, val string, opts ...clientv3.OpOption) (*clientv3.PutResponse, error) {
+	if k.block != nil {
+		k.put <- struct{}{}
+		<-k.block
+	}
+	k.l.Lock()
+	defer k.l.Unlock()
+	k.values[key] = val
+	return &clientv3.PutResponse{}, nil
+}
+
+func (k *kv) Delete(ctx context.Context, key string, opts ...clientv3.OpOption) (*clientv3.DeleteResponse, error) {
+	k.l.Lock()
+	defer k.l.Unlock()
+	delete(k.values, key)
+	return &clientv3.DeleteResponse{}, nil
+}
+
+func (k *kv) get(key string) *registry.Service {
+	k.l.Lock()
+	defer k.l.Unlock()
+	v, ok := k.values[key]
+	if !ok {
+		return nil
+	}
+	svc := &registry.Service{}
+	_ = json.Unmarshal([]byte(v), svc)
+	return svc
+}
+
+func (r *Registry) leaseID(key string) clientv3.LeaseID {
+	r.l.Lock()
+	l, ok := r.leases[key]
+	r.l.Unlock()
+	if !ok {
+		return 0
+	}
+	l.ul.Lock()
+	defer l.ul.Unlock()
+	return l.id
+}
+
+// fakeLease is an in-process fake of the etcd lease
+type fakeLease struct {
+	clientv3.Lease
+	id      clientv3.LeaseID
+	l       sync.Mutex
+	alive   chan struct{}
+	revoked bool
+	closed  bool
+}
+
+func (l *fakeLease) Grant(ctx context.Context, ttl int64) (*clientv3.LeaseGrantResponse, error) {
+	return &clientv3.LeaseGrantResponse{ID: l.id}, nil
+}
+
+func (l *fakeLease) KeepAlive(ctx context.Context, id clientv3.LeaseID) (<-chan *clientv3.LeaseKeepAliveResponse, error) {
+	ch := make(chan *clientv3.LeaseKeepAliveResponse)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+		close(l.alive)
+	}()
+	return ch, nil
+}
+
+func (l *fakeLease) Revoke(ctx context.Context, id clientv3.LeaseID) (*clientv3.LeaseRevokeResponse, error) {
+	l.l.Lock()
+	l.revoked = true
+	l.l.Unlock()
+	return &clientv3.LeaseRevokeResponse{}, nil
+}
+
+func (l *fakeLease) Close() error {
+	l.l.Lock()
+	l.closed = true
+	l.l.Unlock()
+	return nil
+}
+
+func (l *fakeLease) state() (revoked, closed bool) {
+	l.l.Lock()
+	defer l.l.Unlock()
+	return l.revoked, l.closed
+}
+
+func newRegistry() (*Registry, *kv, *[]*fakeLease) {
+	k := &kv{values: map[string]string{}}
+	leases := &[]*fakeLease{}
+	r := &Registry{
+		kv: k,
+		newLease: func() clientv3.Lease {
+			l := &fakeLease{id: clientv3.LeaseID(len(*leases) + 1), alive: make(chan struct{})}
+			*leases = append(*leases, l)
+			return l
+		},
+		opt:    &option{ctx: context.Background(), ns: "/default", ttl: 10, retry: 5},
+		leases: map[string]*lease{},
+	}
+	return r, k, leases
+}
+
+func stopped(l *fakeLease) bool {
+	select {
+	case <-l.alive:
+		return true
+	case <-time.After(time.Second):
+		return false
+	}
+}
+
+func TestLeases(t *testing.T) {
+	r, k, leases := newRegistry()
+	ctx := context.Background()
+	http := &registry.Service{ID: "1", Name: "http"}
+	grpc := &registry.Service{ID: "1", Name: "grpc"}
+	if err := r.Register(ctx, http); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Register(ctx, grpc); err != nil {
+		t.Fatal(err)
+	}
+	// a lease per service
+	hid, gid := r.leaseID("/default/http/1"), r.leaseID("/default/grpc/1")
+	if k.get("/default/http/1") == nil || k.get("/default/grpc/1") == nil || hid == 0 || gid == 0 || hid == gid {
+		t.Fatalf("leases:%d %d", hid, gid)
+	}
+
+	// the keepalive of the service is cancelled by unregister, the others are kept
+	if err := r.Unregister(ctx, http); err != nil {
+		t.Fatal(err)
+	}
+	hl, gl := (*leases)[0], (*leases)[1]
+	if revoked, closed := hl.state(); !stopped(hl) || !revoked || !closed {
+		t.Fatal("keepalive not cancelled")
+	}
+	if k.get("/default/http/1") != nil || r.leaseID("/default/http/1") != 0 {
+		t.Fatal("service not deleted")
+	}
+	select {
+	case <-gl.alive:
+		t.Fatal("keepalive of the other service cancelled")
+	default:
+	}
+
+	// registering again replaces the previous lease
+	if err := r.Register(ctx, grpc); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, closed := gl.state(); !stopped(gl) || !closed || revoked {
+		t.Fatal("previous lease not released")
+	}
+	if id := r.leaseID("/default/grpc/1"); id != (*leases)[2].id {
+		t.Fatalf("lease:%d", id)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	r, k, leases := newRegistry()
+	ctx := context.Background()
+	svc := &registry.Service{ID: "1", Name: "grpc", Metadata: map[string]string{"weight": "50"}}
+	if err := r.Update(ctx, svc); err == nil {
+		t.Fatal("unregistered service updated")
+	}
+	if err := r.Register(ctx, svc); err != nil {
+		t.Fatal(err)
+	}
+	id := r.leaseID("/default/grpc/1")
+
+	// the registry is not locked while putting
+	k.put, k.block = make(chan struct{}), make(chan struct{})
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Update(ctx, &registry.Service{ID: "1", Name: "grpc", Metadata: map[string]string{"weight": "80"}})
+	}()
+	<-k.put
+	unregistered := make(chan error, 1)
+	go func() {
+		unregistered <- r.Unregister(ctx, &registry.Service{ID: "2", Name: "grpc"})
+	}()
+	select {
+	case err := <-unregistered:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("registry locked while putting")
+	}
+	close(k.block)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// the value kept alive is updated with the same lease
+	updated := k.get("/default/grpc/1")
+	if updated.Metadata["weight"] != "80" || r.leaseID("/default/grpc/1") != id || len(*leases) != 1 {
+		t.Fatalf("updated:%+v, leases:%d", updated, len(*leases))
+	}
+	r.l.Lock()
+	l := r.leases["/default/grpc/1"]
+	r.l.Unlock()
+	l.ul.Lock()
+	value := l.value
+	l.ul.Unlock()
+	if err := json.Unmarshal([]byte(value), updated); err != nil || updated.Metadata["weight"] != "80" {
+		t.Fatalf("value kept alive:%s", value)
+	}
+}
+
+// fakeWatcher is an in-process fake of the etcd watcher
+type fakeWatcher struct {
+	clientv3.Watcher
+	wc     chan clientv3.WatchResponse
+	l      sync.Mutex
+	closed bool
+}
+
+func (w *fakeWatcher) Watch(ctx context.Context, key string, opts ...clientv3.OpOption) clientv3.WatchChan {
+	return w.wc
+}
+
+func (w *fakeWatcher) RequestProgress(ctx context.Context) error {
+	return nil
+}
+
+func (w *fakeWatcher) Close() error {
+	w.l.Lock()
+	w.closed = true
+	w.l.Unlock()
+	return nil
+}
+
+func newWatcher() (*watcher, *[]*fakeWatcher) {
+	watchers := &[]*fakeWatcher{}
+	w := &watcher{
+		key: "/default/grpc",
+		newWatcher: func() clientv3.Watcher {
+			fw := &fakeWatcher{wc: make(chan clientv3.WatchResponse)}
+			*watchers = append(*watchers, fw)
+			return fw
+		},
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	w.watcher = w.newWatcher()
+	w.wc = w.watcher.Watch(w.ctx, w.key)
+	return w, watchers
+}
+
+func TestWatcherStop(t *testing.T) {
+	// the watcher reconnected is closed by stop
+	w, watchers := newWatcher()
+	close((*watchers)[0].wc)
+	if err := w.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	_ = w.Stop()
+	if len(*watchers) != 2 || !(*watchers)[0].closed || !(*watchers)[1].closed {
+		t.Fatalf("watchers:%d", len(*watchers))
+	}
+
+	// no watcher is created after stop
+	w, watchers = newWatcher()
+	close((*watchers)[0].wc)
+	done := make(chan error, 1)
+	go func() {
+		done <- w.wait(context.Background())
+	}()
+	_ = w.Stop()
+	if err := <-done; err != registry.ErrWatcherStopped {
+		t.Fatalf("error:%+v", err)
+	}
+	if len(*watchers) != 1 || !(*watchers)[0].closed {
+		t.Fatalf("watchers:%d", len(*watchers))
+	}
+}
@@ -0,0 +1,119 @@
+package file
+
+import (
+	"bytes"
+	"context"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-slark/slark/encoding"
+	"github.com/go-slark/slark/encoding/json"
+	"github.com/go-slark/slark/encoding/yaml"
+	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/logger"
+	"github.com/go-slark/slark/pkg/routine"
+	"github.com/go-slark/slark/registry"
+	"github.com/go-slark/slark/registry/memory"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the file was modified or created
+const writeOrCreateMask = fsnotify.Write | fsnotify.Create
+
+// Registry discovers the services listed in a yaml / json file, the file is reloaded on change
+type Registry struct {
+	services *memory.Registry
+	path     string
+	codec    encoding.Codec
+	watcher  *fsnotify.Watcher
+}
+
+func NewRegistry(path string) (*Registry, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	var codec encoding.Codec
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		codec = encoding.GetCodec(yaml.Name)
+	case ".json":
+		codec = encoding.GetCodec(json.Name)
+	default:
+		return nil, errors.BadRequest("file format unsupported", "FILE_FORMAT_UNSUPPORTED")
+	}
+	r := &Registry{
+		services: memory.NewRegistry(),
+		path:     path,
+		codec:    codec,
+	}
+	err = r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.watcher, err = fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	err = r.watcher.Add(filepath.Dir(path))
+	if err != nil {
+		_ = r.watcher.Close()
+		return nil, err
+	}
+	routine.GoSafe(context.TODO(), r.watch)
+	return r, nil
+}
+
+func (r *Registry) load() error {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return err
+	}
+	// the file is truncated while being written
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	var svc []*registry.Service
+	err = r.codec.Unmarshal(data, &svc)
+	if err != nil {
+		return err
+	}
+	r.services.Reset(svc)
+	return nil
+}
+
+func (r *Registry) watch() {
+	for {
+		select {
+		case event, ok := <-r.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&writeOrCreateMask == 0 || filepath.Clean(event.Name) != r.path {
+				continue
+			}
+			err := r.load()
+			if err != nil {
+				logger.Log(context.TODO(), logger.ErrorLevel, map[string]interface{}{"file": r.path, "error": err}, "registry file reload error")
+			}
+		case err, ok := <-r.watcher.Errors:
+			if !ok {
+				return
+			}
+			logger.Log(context.TODO(), logger.ErrorLevel, map[string]interface{}{"error": err}, "registry file watch error")
+		}
+	}
+}
+
+// Service returns the services sorted by id
+func (r *Registry) Service(ctx context.Context, name string) ([]*registry.Service, error) {
+	return r.services.Service(ctx, name)
+}
+
+func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher, error) {
+	return r.services.Discover(ctx, name)
+}
+
+func (r *Registry) Close() error {
+	return r.watcher.Close()
+}
@@ -0,0 +1,67 @@
+package file
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const services = `
+- id: "1"
+  name: test
+  endpoint:
+    - grpc://127.0.0.1:9090
+- id: "2"
+  name: other
+  endpoint:
+    - grpc://127.0.0.1:9091
+`
+
+func TestFileRegistry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.yaml")
+	err := os.WriteFile(path, []byte(services), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRegistry(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	w, err := r.Discover(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	e, err := w.Next(ctx)
+	if err != nil || len(e.Services) != 1 || e.Services[0].Endpoint[0] != "grpc://127.0.0.1:9090" {
+		t.Fatalf("initial snapshot:%+v, error:%+v", e, err)
+	}
+
+	err = os.WriteFile(path, []byte(services+`
+- id: "3"
+  name: test
+  endpoint:
+    - grpc://127.0.0.1:9092
+  metadata:
+    weight: "50"
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// partial writes may be observed before the final content
+	for len(e.Services) != 2 {
+		e, err = w.Next(ctx)
+		if err != nil {
+			t.Fatalf("reload error:%+v", err)
+		}
+	}
+	if e.Services[1].Metadata["weight"] != "50" {
+		t.Fatalf("reload event:%+v", e)
+	}
+}
@@ -8,29 +8,45 @@ import (
 	"github.com/go-slark/slark/pkg/endpoint"
 	"github.com/go-slark/slark/registry"
 	coreV1 "k8s.io/api/core/v1"
+	discoveryV1 "k8s.io/api/discovery/v1"
 	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	listerCoreV1 "k8s.io/client-go/listers/core/v1"
+	listerDiscoveryV1 "k8s.io/client-go/listers/discovery/v1"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/clientcmd"
 	"net"
 	"net/url"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 const (
-	scheme        = "service-scheme"
+	scheme        = "service-scheme"   // pod annotation: port -> scheme
+	instance      = "service-instance" // pod annotation: registry.Service without endpoint
 	namespacePath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+	podName      = "POD_NAME"
+	podNamespace = "POD_NAMESPACE"
 )
 
 type Registry struct {
-	clientSet *kubernetes.Clientset
-	interval  time.Duration
-	token     string
+	clientSet  kubernetes.Interface
+	interval   time.Duration
+	token      string
+	kubeConfig string
+	insecure   bool
+	label      string
+	pod        string
+	namespace  string
 }
 
 type Option func(*Registry)
@@ -47,67 +63,164 @@ func Token(token string) Option {
 	}
 }
 
-func NewRegistry(opts ...Option) *Registry {
+// KubeConfig :out-of-cluster config file path
+func KubeConfig(path string) Option {
+	return func(r *Registry) {
+		r.kubeConfig = path
+	}
+}
+
+func Insecure(insecure bool) Option {
+	return func(r *Registry) {
+		r.insecure = insecure
+	}
+}
+
+func ClientSet(cs kubernetes.Interface) Option {
+	return func(r *Registry) {
+		r.clientSet = cs
+	}
+}
+
+// Label :pod label whose value is the k8s service name
+func Label(label string) Option {
+	return func(r *Registry) {
+		r.label = label
+	}
+}
+
+// Pod :pod name and namespace, default env POD_NAME / POD_NAMESPACE, hostname and service account namespace
+func Pod(name, namespace string) Option {
+	return func(r *Registry) {
+		r.pod = name
+		r.namespace = namespace
+	}
+}
+
+func NewRegistry(opts ...Option) (*Registry, error) {
 	r := &Registry{
 		interval: 5 * time.Minute,
+		label:    "app",
 	}
 	for _, opt := range opts {
 		opt(r)
 	}
-	config, err := rest.InClusterConfig()
+	if r.clientSet != nil {
+		return r, nil
+	}
+
+	var (
+		config *rest.Config
+		err    error
+	)
+	if len(r.kubeConfig) > 0 {
+		config, err = clientcmd.BuildConfigFromFlags("", r.kubeConfig)
+	} else {
+		config, err = rest.InClusterConfig()
+	}
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	if r.insecure {
+		config.TLSClientConfig = rest.TLSClientConfig{Insecure: true}
+	}
+	if len(r.token) > 0 {
+		config.BearerToken = r.token
+		config.BearerTokenFile = ""
 	}
-	config.TLSClientConfig = rest.TLSClientConfig{Insecure: true}
-	config.BearerToken = r.token
-	config.BearerTokenFile = ""
 	cs, err := kubernetes.NewForConfig(config)
 	if err != nil {
-		panic(fmt.Sprintf("k8s registry error:%+v", err))
+		return nil, err
 	}
 	r.clientSet = cs
-	return r
+	return r, nil
 }
 
-func (r *Registry) Register(ctx context.Context, svc *registry.Service) error {
-	mp, err := endpoint.ParseScheme(svc.Endpoint)
+func (r *Registry) self() (string, string, error) {
+	name, ns := r.pod, r.namespace
+	if len(name) == 0 {
+		name = os.Getenv(podName)
+	}
+	if len(name) == 0 {
+		hn, err := os.Hostname()
+		if err != nil {
+			return "", "", err
+		}
+		name = hn
+	}
+	if len(ns) == 0 {
+		ns = os.Getenv(podNamespace)
+	}
+	if len(ns) == 0 {
+		b, err := os.ReadFile(namespacePath)
+		if err != nil {
+			return "", "", err
+		}
+		ns = strings.TrimSpace(string(b))
+	}
+	return name, ns, nil
+}
+
+func (r *Registry) patch(ctx context.Context, annotations map[string]interface{}) error {
+	name, ns, err := r.self()
 	if err != nil {
 		return err
 	}
-	bytes, err := json.Marshal(mp)
+	pod, err := r.clientSet.CoreV1().Pods(ns).Get(ctx, name, metaV1.GetOptions{})
 	if err != nil {
 		return err
 	}
-	bytes, err = json.Marshal(map[string]interface{}{
-		"metadata": metaV1.ObjectMeta{
-			Annotations: map[string]string{
-				scheme: string(bytes),
-			},
+	if len(pod.Labels[r.label]) == 0 {
+		return errors.InternalServer(fmt.Sprintf("pod label %s not found", r.label), "K8S_POD_LABEL_NOT_FOUND")
+	}
+	bytes, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
 		},
 	})
 	if err != nil {
 		return err
 	}
-	hn, err := os.Hostname()
+	_, err = r.clientSet.CoreV1().Pods(ns).Patch(ctx, name, types.MergePatchType, bytes, metaV1.PatchOptions{})
+	return err
+}
+
+// Register annotates the pod, the pod label value must be the k8s service name
+func (r *Registry) Register(ctx context.Context, svc *registry.Service) error {
+	mp, err := endpoint.ParseScheme(svc.Endpoint)
 	if err != nil {
 		return err
 	}
-	ns, err := os.ReadFile(namespacePath)
+	sb, err := json.Marshal(mp)
 	if err != nil {
 		return err
 	}
-	str := strings.Split(hn, "-")
-	_, err = r.clientSet.CoreV1().Endpoints(string(ns)).Patch(ctx, strings.Join(str[:len(str)-2], "-"), types.StrategicMergePatchType, bytes, metaV1.PatchOptions{})
-	return err
+	ib, err := json.Marshal(&registry.Service{
+		ID:       svc.ID,
+		Name:     svc.Name,
+		Version:  svc.Version,
+		Metadata: svc.Metadata,
+	})
+	if err != nil {
+		return err
+	}
+	return r.patch(ctx, map[string]interface{}{
+		scheme:   string(sb),
+		instance: string(ib),
+	})
 }
 
-func (r *Registry) Unregister(ctx context.Context, svc *registry.Service) error {
-	return nil
+// Unregister removes the pod annotations, the pod is not discovered anymore
+func (r *Registry) Unregister(ctx context.Context, _ *registry.Service) error {
+	return r.patch(ctx, map[string]interface{}{
+		scheme:   nil,
+		instance: nil,
+	})
 }
 
 // name(k8s集群中的服务地址) : service-name.namespace.svc.cluster_name:8080
 
-func (r *Registry) Discover(_ context.Context, name string) (registry.Watcher, error) {
+func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher, error) {
 	str := strings.FieldsFunc(name, func(r rune) bool {
 		return r == ':'
 	})
@@ -121,7 +234,7 @@ func (r *Registry) Discover(_ context.Context, name string) (registry.Watcher, e
 			return nil, err
 		}
 	}
-	str = strings.FieldsFunc(name, func(r rune) bool {
+	str = strings.FieldsFunc(str[0], func(r rune) bool {
 		return r == '.'
 	})
 	if len(str) < 2 {
@@ -129,105 +242,209 @@ func (r *Registry) Discover(_ context.Context, name string) (registry.Watcher, e
 	}
 	name = str[0]
 	ns := str[1]
-	inf := informers.NewSharedInformerFactoryWithOptions(r.clientSet, r.interval,
+
+	sif := informers.NewSharedInformerFactoryWithOptions(r.clientSet, r.interval,
 		informers.WithNamespace(ns),
 		informers.WithTweakListOptions(func(options *metaV1.ListOptions) {
-			options.FieldSelector = "metadata.name=" + name
+			options.LabelSelector = discoveryV1.LabelServiceName + "=" + name
 		}))
-	in := inf.Core().V1().Endpoints()
+	pif := informers.NewSharedInformerFactoryWithOptions(r.clientSet, r.interval,
+		informers.WithNamespace(ns),
+		informers.WithTweakListOptions(func(options *metaV1.ListOptions) {
+			options.LabelSelector = r.label + "=" + name
+		}))
+	slices := sif.Discovery().V1().EndpointSlices()
+	pods := pif.Core().V1().Pods()
+
+	// coalesce events, never block the informer
 	notify := make(chan struct{}, 1)
-	_, err = in.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+	handler := cache.ResourceEventHandlerFuncs{
 		AddFunc: func(obj interface{}) {
-			_, ok := obj.(*coreV1.Endpoints)
-			if !ok {
-				return
-			}
-			notify <- struct{}{}
+			trigger(notify)
 		},
 		UpdateFunc: func(oldObj, newObj interface{}) {
-			oEndpoints, ok := oldObj.(*coreV1.Endpoints)
-			if !ok {
-				return
-			}
-			nEndpoints, ok := newObj.(*coreV1.Endpoints)
-			if !ok {
-				return
-			}
-			if oEndpoints.ResourceVersion == nEndpoints.ResourceVersion {
-				return
-			}
-			notify <- struct{}{}
+			trigger(notify)
 		},
 		DeleteFunc: func(obj interface{}) {
-			_, ok := obj.(*coreV1.Endpoints)
-			if !ok {
-				return
-			}
-			notify <- struct{}{}
+			trigger(notify)
 		},
-	})
+	}
+	_, err = slices.Informer().AddEventHandler(handler)
+	if err != nil {
+		return nil, err
+	}
+	_, err = pods.Informer().AddEventHandler(handler)
 	if err != nil {
 		return nil, err
 	}
-	go inf.Start(context.Background().Done())
 	w := &watcher{
-		clientSet: r.clientSet,
-		notify:    notify,
-		ns:        ns,
-		name:      name,
-		port:      port,
+		slices: slices.Lister().EndpointSlices(ns),
+		pods:   pods.Lister().Pods(ns),
+		synced: []cache.InformerSynced{slices.Informer().HasSynced, pods.Informer().HasSynced},
+		notify: notify,
+		stop:   make(chan struct{}),
+		name:   name,
+		port:   port,
 	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	sif.Start(w.stop)
+	pif.Start(w.stop)
 	return w, nil
 }
 
+func trigger(notify chan struct{}) {
+	select {
+	case notify <- struct{}{}:
+	default:
+	}
+}
+
 type watcher struct {
-	clientSet *kubernetes.Clientset
-	ns        string
-	name      string
-	port      int
-	notify    chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	slices   listerDiscoveryV1.EndpointSliceNamespaceLister
+	pods     listerCoreV1.PodNamespaceLister
+	synced   []cache.InformerSynced
+	name     string
+	port     int
+	notify   chan struct{}
+	stop     chan struct{}
+	once     sync.Once
+	snapshot registry.Snapshot
 }
 
-func (w *watcher) List() ([]*registry.Service, error) {
-	<-w.notify
-	endpoints, err := w.clientSet.CoreV1().Endpoints(w.ns).Get(context.Background(), w.name, metaV1.GetOptions{})
-	if err != nil {
-		return nil, err
+func (w *watcher) Next(ctx context.Context) (*registry.Event, error) {
+	for {
+		if w.ctx.Err() != nil {
+			return nil, registry.ErrWatcherStopped
+		}
+		if w.snapshot.Initialized() {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-w.ctx.Done():
+				return nil, registry.ErrWatcherStopped
+			case <-w.notify:
+			}
+		} else {
+			cx, cancel := context.WithCancel(ctx)
+			go func() {
+				select {
+				case <-w.ctx.Done():
+					cancel()
+				case <-cx.Done():
+				}
+			}()
+			ok := cache.WaitForCacheSync(cx.Done(), w.synced...)
+			cancel()
+			if !ok {
+				if w.ctx.Err() != nil {
+					return nil, registry.ErrWatcherStopped
+				}
+				return nil, ctx.Err()
+			}
+		}
+		svc, err := w.list()
+		if err != nil {
+			return nil, err
+		}
+		e, ok := w.snapshot.Update(svc)
+		if ok {
+			return e, nil
+		}
 	}
-	mp := map[string]string{}
-	err = json.Unmarshal([]byte(endpoints.Annotations[scheme]), &mp)
+}
+
+// list the ready and not terminating endpoints of registered pods
+func (w *watcher) list() ([]*registry.Service, error) {
+	slices, err := w.slices.List(labels.SelectorFromSet(labels.Set{discoveryV1.LabelServiceName: w.name}))
 	if err != nil {
 		return nil, err
 	}
-	svc := make([]*registry.Service, 0, len(endpoints.Subsets))
-	// meta.name --> len(endpoints.Subsets) == 1
-	for _, set := range endpoints.Subsets {
-		if len(set.Ports) == 0 {
-			break
-		}
-		if w.port == 0 {
-			w.port = int(set.Ports[0].Port)
-		}
-		for _, addr := range set.Addresses {
-			port := fmt.Sprintf("%d", w.port)
-			u := &url.URL{
-				Scheme: mp[port],
-				Host:   net.JoinHostPort(addr.IP, port),
+	mp := map[string]*registry.Service{}
+	for _, slice := range slices {
+		for _, ep := range slice.Endpoints {
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				continue
 			}
-			s := &registry.Service{
-				Name:     endpoints.Name,
-				Version:  endpoints.ResourceVersion,
-				Endpoint: []string{u.String()},
+			if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+				continue
 			}
-			if addr.TargetRef != nil {
-				s.ID = string(addr.TargetRef.UID)
+			if len(ep.Addresses) == 0 || ep.TargetRef == nil || ep.TargetRef.Kind != "Pod" {
+				continue
+			}
+			pod, err := w.pods.Get(ep.TargetRef.Name)
+			if err != nil {
+				continue
+			}
+			svc, schemes, ok := parse(pod)
+			if !ok {
+				// unregistered
+				continue
 			}
-			svc = append(svc, s)
+			for _, p := range slice.Ports {
+				if p.Port == nil || (w.port > 0 && int(*p.Port) != w.port) {
+					continue
+				}
+				port := strconv.Itoa(int(*p.Port))
+				s, ok := schemes[port]
+				if !ok {
+					continue
+				}
+				u := &url.URL{
+					Scheme: s,
+					Host:   net.JoinHostPort(ep.Addresses[0], port),
+				}
+				svc.Endpoint = append(svc.Endpoint, u.String())
+			}
+			if len(svc.Endpoint) == 0 {
+				continue
+			}
+			s, ok := mp[svc.ID]
+			if ok {
+				s.Endpoint = append(s.Endpoint, svc.Endpoint...)
+				continue
+			}
+			mp[svc.ID] = svc
 		}
 	}
+	svc := make([]*registry.Service, 0, len(mp))
+	for _, s := range mp {
+		sort.Strings(s.Endpoint)
+		svc = append(svc, s)
+	}
+	sort.Slice(svc, func(i, j int) bool {
+		return svc[i].ID < svc[j].ID
+	})
 	return svc, nil
 }
 
+func parse(pod *coreV1.Pod) (*registry.Service, map[string]string, bool) {
+	sv, ok := pod.Annotations[scheme]
+	if !ok {
+		return nil, nil, false
+	}
+	schemes := map[string]string{}
+	err := json.Unmarshal([]byte(sv), &schemes)
+	if err != nil {
+		return nil, nil, false
+	}
+	svc := &registry.Service{}
+	iv, ok := pod.Annotations[instance]
+	if ok {
+		_ = json.Unmarshal([]byte(iv), svc)
+	}
+	if len(svc.ID) == 0 {
+		svc.ID = string(pod.UID)
+	}
+	svc.Endpoint = nil
+	return svc, schemes, true
+}
+
 func (w *watcher) Stop() error {
+	w.once.Do(func() {
+		w.cancel()
+		close(w.stop)
+	})
 	return nil
 }
@@ -2,23 +2,92 @@ package k8s
 
 import (
 	"context"
-	"fmt"
+	"github.com/go-slark/slark/registry"
+	coreV1 "k8s.io/api/core/v1"
+	discoveryV1 "k8s.io/api/discovery/v1"
+	metaV1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 	"testing"
+	"time"
 )
 
-func TestK8sDiscovery(t *testing.T) {
-	// discovery:///svc-name.test.svc.cluster_name
-	registry := NewRegistry(Token("token"))
-	w, err := registry.Discover(context.TODO(), "svc-name.test.svc.cluster_name:9090")
+func TestK8sRegistry(t *testing.T) {
+	ctx := context.Background()
+	cs := fake.NewSimpleClientset(&coreV1.Pod{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "pod-1",
+			Namespace: "test",
+			UID:       "uid-1",
+			Labels:    map[string]string{"app": "svc-name"},
+		},
+	})
+	ready, port := true, int32(9090)
+	_, err := cs.DiscoveryV1().EndpointSlices("test").Create(ctx, &discoveryV1.EndpointSlice{
+		ObjectMeta: metaV1.ObjectMeta{
+			Name:      "svc-name-abc",
+			Namespace: "test",
+			Labels:    map[string]string{discoveryV1.LabelServiceName: "svc-name"},
+		},
+		AddressType: discoveryV1.AddressTypeIPv4,
+		Endpoints: []discoveryV1.Endpoint{{
+			Addresses:  []string{"10.0.0.1"},
+			Conditions: discoveryV1.EndpointConditions{Ready: &ready},
+			TargetRef:  &coreV1.ObjectReference{Kind: "Pod", Name: "pod-1", Namespace: "test"},
+		}},
+		Ports: []discoveryV1.EndpointPort{{Port: &port}},
+	}, metaV1.CreateOptions{})
 	if err != nil {
-		fmt.Printf("registery discover err:%+v\n", err)
-		return
+		t.Fatal(err)
 	}
-	_, err = w.List()
+
+	r, err := NewRegistry(ClientSet(cs), Pod("pod-1", "test"))
 	if err != nil {
-		fmt.Printf("registery list err:%+v\n", err)
-		return
+		t.Fatal(err)
+	}
+	w, err := r.Discover(ctx, "svc-name.test.svc.cluster_name:9090")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Stop()
+	e, err := w.Next(ctx)
+	if err != nil || len(e.Services) != 0 {
+		t.Fatalf("initial snapshot:%+v, error:%+v", e, err)
 	}
-}
 
-// windows设置host(api server地址) 192.168.xx.xx xxxx.ccs.tencent-cloud.com
+	svc := &registry.Service{
+		ID:       "1",
+		Name:     "svc-name",
+		Version:  "v1",
+		Endpoint: []string{"grpc://127.0.0.1:9090"},
+		Metadata: map[string]string{"region": "sh"},
+	}
+	err = r.Register(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	e, err = w.Next(cx)
+	if err != nil || len(e.Added) != 1 {
+		t.Fatalf("register event:%+v, error:%+v", e, err)
+	}
+	s := e.Added[0]
+	if s.ID != "1" || s.Version != "v1" || s.Metadata["region"] != "sh" || len(s.Endpoint) != 1 || s.Endpoint[0] != "grpc://10.0.0.1:9090" {
+		t.Fatalf("service:%+v", s)
+	}
+
+	err = r.Unregister(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(cx)
+	if err != nil || len(e.Removed) != 1 {
+		t.Fatalf("unregister event:%+v, error:%+v", e, err)
+	}
+
+	_ = w.Stop()
+	_, err = w.Next(ctx)
+	if err != registry.ErrWatcherStopped {
+		t.Fatalf("stop error:%+v", err)
+	}
+}
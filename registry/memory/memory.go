@@ -0,0 +1,160 @@
+package memory
+
+import (
+	"context"
+	"github.com/go-slark/slark/registry"
+	"sort"
+	"sync"
+)
+
+// Registry is an in-process registry for tests and local development
+type Registry struct {
+	l        sync.RWMutex
+	services map[string]map[string]*registry.Service
+	watchers map[string]map[*watcher]struct{}
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		services: map[string]map[string]*registry.Service{},
+		watchers: map[string]map[*watcher]struct{}{},
+	}
+}
+
+func (r *Registry) Register(_ context.Context, svc *registry.Service) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	mp, ok := r.services[svc.Name]
+	if !ok {
+		mp = map[string]*registry.Service{}
+		r.services[svc.Name] = mp
+	}
+	mp[svc.ID] = clone(svc)
+	r.notify(svc.Name)
+	return nil
+}
+
+// Reset replaces all the services
+func (r *Registry) Reset(svc []*registry.Service) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	services := make(map[string]map[string]*registry.Service, len(svc))
+	for _, s := range svc {
+		mp, ok := services[s.Name]
+		if !ok {
+			mp = map[string]*registry.Service{}
+			services[s.Name] = mp
+		}
+		mp[s.ID] = clone(s)
+	}
+	r.services = services
+	for name := range r.watchers {
+		r.notify(name)
+	}
+}
+
+func clone(svc *registry.Service) *registry.Service {
+	s := *svc
+	s.Endpoint = append([]string(nil), svc.Endpoint...)
+	if svc.Metadata != nil {
+		s.Metadata = make(map[string]string, len(svc.Metadata))
+		for k, v := range svc.Metadata {
+			s.Metadata[k] = v
+		}
+	}
+	return &s
+}
+
+func (r *Registry) Unregister(_ context.Context, svc *registry.Service) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	delete(r.services[svc.Name], svc.ID)
+	r.notify(svc.Name)
+	return nil
+}
+
+// Service returns the services sorted by id
+func (r *Registry) Service(_ context.Context, name string) ([]*registry.Service, error) {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	return r.service(name), nil
+}
+
+func (r *Registry) service(name string) []*registry.Service {
+	svc := make([]*registry.Service, 0, len(r.services[name]))
+	for _, s := range r.services[name] {
+		svc = append(svc, s)
+	}
+	sort.Slice(svc, func(i, j int) bool {
+		return svc[i].ID < svc[j].ID
+	})
+	return svc
+}
+
+func (r *Registry) notify(name string) {
+	for w := range r.watchers[name] {
+		select {
+		case w.notify <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher, error) {
+	w := &watcher{
+		r:      r,
+		name:   name,
+		notify: make(chan struct{}, 1),
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	r.l.Lock()
+	mp, ok := r.watchers[name]
+	if !ok {
+		mp = map[*watcher]struct{}{}
+		r.watchers[name] = mp
+	}
+	mp[w] = struct{}{}
+	r.l.Unlock()
+	return w, nil
+}
+
+type watcher struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	r        *Registry
+	name     string
+	notify   chan struct{}
+	snapshot registry.Snapshot
+}
+
+func (w *watcher) Next(ctx context.Context) (*registry.Event, error) {
+	for {
+		if w.ctx.Err() != nil {
+			return nil, registry.ErrWatcherStopped
+		}
+		if w.snapshot.Initialized() {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-w.ctx.Done():
+				return nil, registry.ErrWatcherStopped
+			case <-w.notify:
+			}
+		}
+		w.r.l.RLock()
+		svc := w.r.service(w.name)
+		w.r.l.RUnlock()
+		e, ok := w.snapshot.Update(svc)
+		if ok {
+			return e, nil
+		}
+	}
+}
+
+func (w *watcher) Stop() error {
+	w.cancel()
+	w.r.l.Lock()
+	delete(w.r.watchers[w.name], w)
+	w.r.l.Unlock()
+	return nil
+}
@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"github.com/go-slark/slark/registry"
+	"testing"
+	"time"
+)
+
+func TestMemoryRegistry(t *testing.T) {
+	r := NewRegistry()
+	ctx := context.Background()
+	svc := &registry.Service{ID: "1", Name: "test", Endpoint: []string{"grpc://127.0.0.1:9090"}}
+	err := r.Register(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := r.Discover(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := w.Next(ctx)
+	if err != nil || len(e.Services) != 1 {
+		t.Fatalf("initial snapshot:%+v, error:%+v", e, err)
+	}
+
+	svc.Metadata = map[string]string{"weight": "50"}
+	_ = r.Register(ctx, svc)
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Updated) != 1 || e.Updated[0].Metadata["weight"] != "50" {
+		t.Fatalf("update event:%+v, error:%+v", e, err)
+	}
+
+	_ = r.Unregister(ctx, svc)
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Removed) != 1 {
+		t.Fatalf("unregister event:%+v, error:%+v", e, err)
+	}
+
+	cx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = w.Next(cx)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("next must block:%+v", err)
+	}
+	_ = w.Stop()
+	_, err = w.Next(ctx)
+	if err != registry.ErrWatcherStopped {
+		t.Fatalf("stop error:%+v", err)
+	}
+}
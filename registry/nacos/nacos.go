@@ -0,0 +1,240 @@
+package nacos
+
+import (
+	"context"
+	"fmt"
+	utils "github.com/go-slark/slark/pkg"
+	"github.com/go-slark/slark/registry"
+	"github.com/nacos-group/nacos-sdk-go/v2/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/v2/model"
+	"github.com/nacos-group/nacos-sdk-go/v2/vo"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	id      = "id"
+	version = "version"
+	scheme  = "scheme"
+
+	heartbeatTimeout = "preserved.heart.beat.timeout"
+	deleteTimeout    = "preserved.ip.delete.timeout"
+)
+
+type Registry struct {
+	client naming_client.INamingClient
+	opt    *option
+}
+
+func NewRegistry(client naming_client.INamingClient, opts ...Option) *Registry {
+	opt := &option{
+		group:   "DEFAULT_GROUP",
+		cluster: "DEFAULT",
+		ttl:     15 * time.Second,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	return &Registry{
+		client: client,
+		opt:    opt,
+	}
+}
+
+// Register an ephemeral instance for each endpoint
+func (r *Registry) Register(_ context.Context, svc *registry.Service) error {
+	weight := float64(100)
+	w, ok := svc.Metadata[utils.Weight]
+	if ok {
+		v, err := strconv.ParseFloat(w, 64)
+		if err == nil && v > 0 {
+			weight = v
+		}
+	}
+	for _, endpoint := range svc.Endpoint {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		port, err := strconv.ParseUint(u.Port(), 10, 64)
+		if err != nil {
+			return err
+		}
+		md := make(map[string]string, len(svc.Metadata)+5)
+		for k, v := range svc.Metadata {
+			md[k] = v
+		}
+		md[id] = svc.ID
+		md[version] = svc.Version
+		md[scheme] = u.Scheme
+		md[heartbeatTimeout] = strconv.FormatInt(r.opt.ttl.Milliseconds(), 10)
+		md[deleteTimeout] = strconv.FormatInt(2*r.opt.ttl.Milliseconds(), 10)
+		_, err = r.client.RegisterInstance(vo.RegisterInstanceParam{
+			Ip:          u.Hostname(),
+			Port:        port,
+			Weight:      weight,
+			Enable:      true,
+			Healthy:     true,
+			Metadata:    md,
+			ClusterName: r.opt.cluster,
+			ServiceName: svc.Name,
+			GroupName:   r.opt.group,
+			Ephemeral:   true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) Unregister(_ context.Context, svc *registry.Service) error {
+	for _, endpoint := range svc.Endpoint {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return err
+		}
+		port, err := strconv.ParseUint(u.Port(), 10, 64)
+		if err != nil {
+			return err
+		}
+		_, err = r.client.DeregisterInstance(vo.DeregisterInstanceParam{
+			Ip:          u.Hostname(),
+			Port:        port,
+			Cluster:     r.opt.cluster,
+			ServiceName: svc.Name,
+			GroupName:   r.opt.group,
+			Ephemeral:   true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher, error) {
+	w := &watcher{
+		client: r.client,
+		name:   name,
+		group:  r.opt.group,
+		notify: make(chan struct{}, 1),
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	w.param = &vo.SubscribeParam{
+		ServiceName: name,
+		GroupName:   r.opt.group,
+		SubscribeCallback: func(services []model.Instance, err error) {
+			select {
+			case w.notify <- struct{}{}:
+			default:
+			}
+		},
+	}
+	err := r.client.Subscribe(w.param)
+	if err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+type watcher struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	client   naming_client.INamingClient
+	param    *vo.SubscribeParam
+	name     string
+	group    string
+	notify   chan struct{}
+	once     sync.Once
+	snapshot registry.Snapshot
+}
+
+func (w *watcher) Next(ctx context.Context) (*registry.Event, error) {
+	for {
+		if w.ctx.Err() != nil {
+			return nil, registry.ErrWatcherStopped
+		}
+		if w.snapshot.Initialized() {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-w.ctx.Done():
+				return nil, registry.ErrWatcherStopped
+			case <-w.notify:
+			}
+		}
+		svc, err := w.service()
+		if err != nil {
+			return nil, err
+		}
+		e, ok := w.snapshot.Update(svc)
+		if ok {
+			return e, nil
+		}
+	}
+}
+
+// service merges the instances of the same service id
+func (w *watcher) service() ([]*registry.Service, error) {
+	instances, err := w.client.SelectInstances(vo.SelectInstancesParam{
+		ServiceName: w.name,
+		GroupName:   w.group,
+		HealthyOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	mp := make(map[string]*registry.Service, len(instances))
+	svc := make([]*registry.Service, 0, len(instances))
+	for _, in := range instances {
+		key := in.Metadata[id]
+		if len(key) == 0 {
+			key = in.InstanceId
+		}
+		s, ok := mp[key]
+		if !ok {
+			s = &registry.Service{
+				ID:       key,
+				Name:     w.name,
+				Version:  in.Metadata[version],
+				Metadata: map[string]string{},
+			}
+			for k, v := range in.Metadata {
+				switch k {
+				case id, version, scheme, heartbeatTimeout, deleteTimeout:
+				default:
+					s.Metadata[k] = v
+				}
+			}
+			_, ok = s.Metadata[utils.Weight]
+			if !ok && in.Weight > 0 {
+				s.Metadata[utils.Weight] = strconv.FormatInt(int64(in.Weight), 10)
+			}
+			mp[key] = s
+			svc = append(svc, s)
+		}
+		u := &url.URL{
+			Scheme: in.Metadata[scheme],
+			Host:   net.JoinHostPort(in.Ip, fmt.Sprintf("%d", in.Port)),
+		}
+		s.Endpoint = append(s.Endpoint, u.String())
+	}
+	for _, s := range svc {
+		sort.Strings(s.Endpoint)
+	}
+	return svc, nil
+}
+
+func (w *watcher) Stop() error {
+	var err error
+	w.once.Do(func() {
+		w.cancel()
+		err = w.client.Unsubscribe(w.param)
+	})
+	return err
+}
@@ -0,0 +1,135 @@
+package nacos
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-slark/slark/registry"
+	"github.com/nacos-group/nacos-sdk-go/v2/clients/naming_client"
+	"github.com/nacos-group/nacos-sdk-go/v2/model"
+	"github.com/nacos-group/nacos-sdk-go/v2/vo"
+	"sync"
+	"testing"
+	"time"
+)
+
+// client is an in-process fake of the nacos naming client
+type client struct {
+	naming_client.INamingClient
+	l         sync.Mutex
+	instances map[string]model.Instance
+	callbacks []func([]model.Instance, error)
+}
+
+func newClient() *client {
+	return &client{instances: map[string]model.Instance{}}
+}
+
+func (c *client) notify() {
+	for _, cb := range c.callbacks {
+		cb(nil, nil)
+	}
+}
+
+func (c *client) RegisterInstance(param vo.RegisterInstanceParam) (bool, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	key := fmt.Sprintf("%s:%d", param.Ip, param.Port)
+	c.instances[key] = model.Instance{
+		InstanceId:  key,
+		Ip:          param.Ip,
+		Port:        param.Port,
+		Weight:      param.Weight,
+		Healthy:     param.Healthy,
+		Enable:      param.Enable,
+		Ephemeral:   param.Ephemeral,
+		ServiceName: param.GroupName + "@@" + param.ServiceName,
+		Metadata:    param.Metadata,
+	}
+	c.notify()
+	return true, nil
+}
+
+func (c *client) DeregisterInstance(param vo.DeregisterInstanceParam) (bool, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	delete(c.instances, fmt.Sprintf("%s:%d", param.Ip, param.Port))
+	c.notify()
+	return true, nil
+}
+
+func (c *client) SelectInstances(param vo.SelectInstancesParam) ([]model.Instance, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	instances := make([]model.Instance, 0, len(c.instances))
+	for _, in := range c.instances {
+		if in.ServiceName == param.GroupName+"@@"+param.ServiceName {
+			instances = append(instances, in)
+		}
+	}
+	return instances, nil
+}
+
+func (c *client) Subscribe(param *vo.SubscribeParam) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.callbacks = append(c.callbacks, param.SubscribeCallback)
+	return nil
+}
+
+func (c *client) Unsubscribe(param *vo.SubscribeParam) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.callbacks = nil
+	return nil
+}
+
+func TestNacosRegistry(t *testing.T) {
+	r := NewRegistry(newClient())
+	ctx := context.Background()
+	w, err := r.Discover(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := w.Next(ctx)
+	if err != nil || len(e.Services) != 0 {
+		t.Fatalf("initial snapshot:%+v, error:%+v", e, err)
+	}
+
+	svc := &registry.Service{
+		ID:       "1",
+		Name:     "test",
+		Version:  "v1",
+		Endpoint: []string{"grpc://127.0.0.1:9090", "http://127.0.0.1:8080"},
+		Metadata: map[string]string{"weight": "50", "region": "sh"},
+	}
+	err = r.Register(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Added) != 1 {
+		t.Fatalf("register event:%+v, error:%+v", e, err)
+	}
+	s := e.Added[0]
+	if s.ID != "1" || s.Version != "v1" || s.Metadata["weight"] != "50" || s.Metadata["region"] != "sh" || len(s.Endpoint) != 2 {
+		t.Fatalf("service:%+v", s)
+	}
+
+	err = r.Unregister(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Removed) != 1 {
+		t.Fatalf("unregister event:%+v, error:%+v", e, err)
+	}
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = w.Stop()
+	}()
+	_, err = w.Next(ctx)
+	if err != registry.ErrWatcherStopped {
+		t.Fatalf("stop error:%+v", err)
+	}
+}
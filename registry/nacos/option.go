@@ -0,0 +1,30 @@
+package nacos
+
+import "time"
+
+type option struct {
+	group   string
+	cluster string
+	ttl     time.Duration
+}
+
+type Option func(*option)
+
+func Group(group string) Option {
+	return func(o *option) {
+		o.group = group
+	}
+}
+
+func Cluster(cluster string) Option {
+	return func(o *option) {
+		o.cluster = cluster
+	}
+}
+
+// TTL :the ephemeral instance is unhealthy without heartbeat within ttl, and removed after 2*ttl
+func TTL(ttl time.Duration) Option {
+	return func(o *option) {
+		o.ttl = ttl
+	}
+}
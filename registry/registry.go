@@ -1,6 +1,14 @@
 package registry
 
-import "context"
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+var ErrWatcherStopped = errors.New("watcher stopped")
 
 type Service struct {
 	ID       string            `json:"id"`
@@ -19,7 +27,81 @@ type Discovery interface {
 	Discover(ctx context.Context, name string) (Watcher, error)
 }
 
+// Watcher :the first Next returns the initial snapshot immediately, then Next blocks until the services change.
+// Stop is idempotent, it unblocks Next and the following Next returns ErrWatcherStopped.
+// Next is not safe for concurrent use.
 type Watcher interface {
-	List() ([]*Service, error)
+	Next(ctx context.Context) (*Event, error)
 	Stop() error
 }
+
+// Event :Services is the full snapshot, Added / Removed / Updated are the deltas since the previous event
+type Event struct {
+	Services []*Service
+	Added    []*Service
+	Removed  []*Service
+	Updated  []*Service
+}
+
+func (e *Event) Changed() bool {
+	return len(e.Added) > 0 || len(e.Removed) > 0 || len(e.Updated) > 0
+}
+
+// NewEvent builds the event from the previous snapshot to the current one
+func NewEvent(prev, cur []*Service) *Event {
+	e := &Event{Services: cur}
+	mp := make(map[string]*Service, len(prev))
+	for _, svc := range prev {
+		mp[svc.key()] = svc
+	}
+	for _, svc := range cur {
+		key := svc.key()
+		p, ok := mp[key]
+		if !ok {
+			e.Added = append(e.Added, svc)
+			continue
+		}
+		delete(mp, key)
+		if !reflect.DeepEqual(p, svc) {
+			e.Updated = append(e.Updated, svc)
+		}
+	}
+	for _, svc := range prev {
+		_, ok := mp[svc.key()]
+		if ok {
+			e.Removed = append(e.Removed, svc)
+		}
+	}
+	return e
+}
+
+// Snapshot keeps the last services delivered by a watcher
+type Snapshot struct {
+	services []*Service
+	init     bool
+}
+
+func (s *Snapshot) Initialized() bool {
+	return s.init
+}
+
+// Update returns the event and whether it should be delivered, the initial snapshot is always delivered
+func (s *Snapshot) Update(cur []*Service) (*Event, bool) {
+	e := NewEvent(s.services, cur)
+	if s.init && !e.Changed() {
+		return e, false
+	}
+	s.init = true
+	s.services = cur
+	return e, true
+}
+
+func (s *Service) key() string {
+	if len(s.ID) > 0 {
+		return s.ID
+	}
+	endpoint := make([]string, len(s.Endpoint))
+	copy(endpoint, s.Endpoint)
+	sort.Strings(endpoint)
+	return strings.Join(endpoint, ",")
+}
@@ -0,0 +1,29 @@
+package registry
+
+import "testing"
+
+func TestSnapshot(t *testing.T) {
+	s := &Snapshot{}
+	e, ok := s.Update(nil)
+	if !ok || e.Changed() {
+		t.Fatalf("initial snapshot must be delivered:%+v", e)
+	}
+
+	a := &Service{ID: "a", Name: "svc", Endpoint: []string{"grpc://127.0.0.1:9090"}}
+	b := &Service{ID: "b", Name: "svc", Endpoint: []string{"grpc://127.0.0.1:9091"}}
+	e, ok = s.Update([]*Service{a, b})
+	if !ok || len(e.Added) != 2 || len(e.Services) != 2 {
+		t.Fatalf("added event error:%+v", e)
+	}
+
+	_, ok = s.Update([]*Service{a, b})
+	if ok {
+		t.Fatal("unchanged snapshot must not be delivered")
+	}
+
+	c := &Service{ID: "b", Name: "svc", Endpoint: []string{"grpc://127.0.0.1:9091"}, Metadata: map[string]string{"weight": "50"}}
+	e, ok = s.Update([]*Service{c})
+	if !ok || len(e.Updated) != 1 || len(e.Removed) != 1 || e.Removed[0].ID != "a" {
+		t.Fatalf("update event error:%+v", e)
+	}
+}
@@ -0,0 +1,23 @@
+package zookeeper
+
+import "time"
+
+type option struct {
+	ns       string
+	interval time.Duration
+}
+
+type Option func(*option)
+
+func Namespace(ns string) Option {
+	return func(o *option) {
+		o.ns = ns
+	}
+}
+
+// Interval :check interval of the ephemeral node, it is recreated after session expiry
+func Interval(interval time.Duration) Option {
+	return func(o *option) {
+		o.interval = interval
+	}
+}
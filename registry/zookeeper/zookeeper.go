@@ -0,0 +1,295 @@
+package zookeeper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/go-slark/slark/logger"
+	"github.com/go-slark/slark/registry"
+	"github.com/go-zookeeper/zk"
+	"path"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Conn is implemented by *zk.Conn
+type Conn interface {
+	Create(path string, data []byte, flags int32, acl []zk.ACL) (string, error)
+	Set(path string, data []byte, version int32) (*zk.Stat, error)
+	Delete(path string, version int32) error
+	Exists(path string) (bool, *zk.Stat, error)
+	Get(path string) ([]byte, *zk.Stat, error)
+	GetW(path string) ([]byte, *zk.Stat, <-chan zk.Event, error)
+	ChildrenW(path string) ([]string, *zk.Stat, <-chan zk.Event, error)
+}
+
+type Registry struct {
+	conn    Conn
+	opt     *option
+	l       sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+// NewRegistry :services are registered as ephemeral nodes, they are removed by zookeeper after the session expires
+func NewRegistry(conn Conn, opts ...Option) *Registry {
+	opt := &option{
+		ns:       "/slark",
+		interval: 10 * time.Second,
+	}
+	for _, o := range opts {
+		o(opt)
+	}
+	return &Registry{
+		conn:    conn,
+		opt:     opt,
+		cancels: map[string]context.CancelFunc{},
+	}
+}
+
+func (r *Registry) Register(ctx context.Context, svc *registry.Service) error {
+	value, err := json.Marshal(svc)
+	if err != nil {
+		return err
+	}
+	dir := path.Join(r.opt.ns, svc.Name)
+	err = r.mkdir(dir)
+	if err != nil {
+		return err
+	}
+	key := path.Join(dir, svc.ID)
+	err = r.put(key, value)
+	if err != nil {
+		return err
+	}
+
+	cx, cancel := context.WithCancel(context.Background())
+	r.l.Lock()
+	c, ok := r.cancels[key]
+	if ok {
+		c()
+	}
+	r.cancels[key] = cancel
+	r.l.Unlock()
+	go r.keepAlive(cx, key, value)
+	return nil
+}
+
+func (r *Registry) put(key string, value []byte) error {
+	_, err := r.conn.Create(key, value, zk.FlagEphemeral, zk.WorldACL(zk.PermAll))
+	if errors.Is(err, zk.ErrNodeExists) {
+		_, err = r.conn.Set(key, value, -1)
+	}
+	return err
+}
+
+// mkdir creates the persistent parent nodes
+func (r *Registry) mkdir(dir string) error {
+	p := ""
+	for _, node := range strings.Split(strings.Trim(dir, "/"), "/") {
+		p += "/" + node
+		ok, _, err := r.conn.Exists(p)
+		if err != nil {
+			return err
+		}
+		if ok {
+			continue
+		}
+		_, err = r.conn.Create(p, nil, 0, zk.WorldACL(zk.PermAll))
+		if err != nil && !errors.Is(err, zk.ErrNodeExists) {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepAlive recreates the ephemeral node lost after session expiry
+func (r *Registry) keepAlive(ctx context.Context, key string, value []byte) {
+	ticker := time.NewTicker(r.opt.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			ok, _, err := r.conn.Exists(key)
+			if err == nil && !ok {
+				err = r.put(key, value)
+			}
+			if err != nil {
+				logger.Log(ctx, logger.ErrorLevel, map[string]interface{}{"key": key, "error": err}, "zookeeper keepalive error")
+			}
+		}
+	}
+}
+
+func (r *Registry) Unregister(ctx context.Context, svc *registry.Service) error {
+	key := path.Join(r.opt.ns, svc.Name, svc.ID)
+	r.l.Lock()
+	cancel, ok := r.cancels[key]
+	if ok {
+		cancel()
+		delete(r.cancels, key)
+	}
+	r.l.Unlock()
+	err := r.conn.Delete(key, -1)
+	if errors.Is(err, zk.ErrNoNode) {
+		return nil
+	}
+	return err
+}
+
+func (r *Registry) Discover(ctx context.Context, name string) (registry.Watcher, error) {
+	dir := path.Join(r.opt.ns, name)
+	err := r.mkdir(dir)
+	if err != nil {
+		return nil, err
+	}
+	w := &watcher{
+		conn: r.conn,
+		path: dir,
+		name: name,
+	}
+	w.ctx, w.cancel = context.WithCancel(ctx)
+	return w, nil
+}
+
+type watcher struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	conn     Conn
+	path     string
+	name     string
+	event    <-chan zk.Event
+	names    []string
+	children map[string]*child
+	snapshot registry.Snapshot
+}
+
+// child is the service of a child node, event is the data watch of the node, nil once fired
+type child struct {
+	svc   *registry.Service
+	event <-chan zk.Event
+}
+
+// Next watches the children and the data of each child, so that the metadata updated in place is observed too
+func (w *watcher) Next(ctx context.Context) (*registry.Event, error) {
+	for {
+		if w.ctx.Err() != nil {
+			return nil, registry.ErrWatcherStopped
+		}
+		if w.snapshot.Initialized() {
+			err := w.wait(ctx)
+			if err != nil {
+				return nil, err
+			}
+		}
+		svc, err := w.service()
+		if err != nil {
+			return nil, err
+		}
+		e, ok := w.snapshot.Update(svc)
+		if ok {
+			return e, nil
+		}
+	}
+}
+
+// wait blocks until the children or the data of any child changes
+func (w *watcher) wait(ctx context.Context) error {
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(w.ctx.Done())},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(w.event)},
+	}
+	names := make([]string, 0, len(w.children))
+	for name, c := range w.children {
+		if c.event == nil {
+			continue
+		}
+		names = append(names, name)
+		cases = append(cases, reflect.SelectCase{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(c.event)})
+	}
+	i, _, _ := reflect.Select(cases)
+	switch i {
+	case 0:
+		return ctx.Err()
+	case 1:
+		return registry.ErrWatcherStopped
+	case 2:
+		w.event = nil
+	default:
+		w.children[names[i-3]].event = nil
+	}
+	return nil
+}
+
+func (w *watcher) service() ([]*registry.Service, error) {
+	if w.event != nil {
+		select {
+		case <-w.event:
+			w.event = nil
+		default:
+		}
+	}
+	// the children watch is set once until fired, the names are unchanged before
+	if w.event == nil {
+		names, _, event, err := w.conn.ChildrenW(w.path)
+		if err != nil {
+			return nil, err
+		}
+		w.names, w.event = names, event
+	}
+	children := make(map[string]*child, len(w.names))
+	svc := make([]*registry.Service, 0, len(w.names))
+	var err error
+	for _, name := range w.names {
+		c, ok := w.children[name]
+		if ok && c.event != nil {
+			select {
+			case <-c.event:
+				c.event = nil
+			default:
+			}
+		}
+		// the data watch of a node is set once until fired
+		if !ok || c.event == nil {
+			c, err = w.child(name)
+			if errors.Is(err, zk.ErrNoNode) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+		children[name] = c
+		if c.svc != nil {
+			svc = append(svc, c.svc)
+		}
+	}
+	w.children = children
+	return svc, nil
+}
+
+func (w *watcher) child(name string) (*child, error) {
+	value, _, event, err := w.conn.GetW(path.Join(w.path, name))
+	if err != nil {
+		return nil, err
+	}
+	s := &registry.Service{}
+	err = json.Unmarshal(value, s)
+	if err != nil {
+		return nil, err
+	}
+	c := &child{event: event}
+	if s.Name == w.name {
+		c.svc = s
+	}
+	return c, nil
+}
+
+func (w *watcher) Stop() error {
+	w.cancel()
+	return nil
+}
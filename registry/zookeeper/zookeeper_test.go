@@ -0,0 +1,210 @@
+package zookeeper
+
+import (
+	"context"
+	"github.com/go-slark/slark/registry"
+	"github.com/go-zookeeper/zk"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// conn is an in-process fake of the zookeeper tree
+type conn struct {
+	l       sync.Mutex
+	nodes   map[string][]byte
+	watches map[string][]chan zk.Event
+	data    map[string][]chan zk.Event
+}
+
+func newConn() *conn {
+	return &conn{nodes: map[string][]byte{}, watches: map[string][]chan zk.Event{}, data: map[string][]chan zk.Event{}}
+}
+
+func (c *conn) notifyData(p string, typ zk.EventType) {
+	for _, ch := range c.data[p] {
+		ch <- zk.Event{Type: typ, Path: p}
+		close(ch)
+	}
+	delete(c.data, p)
+}
+
+func (c *conn) notify(p string) {
+	dir := path.Dir(p)
+	for _, ch := range c.watches[dir] {
+		ch <- zk.Event{Type: zk.EventNodeChildrenChanged, Path: dir}
+		close(ch)
+	}
+	delete(c.watches, dir)
+}
+
+func (c *conn) Create(p string, data []byte, flags int32, acl []zk.ACL) (string, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	_, ok := c.nodes[p]
+	if ok {
+		return "", zk.ErrNodeExists
+	}
+	c.nodes[p] = data
+	c.notify(p)
+	return p, nil
+}
+
+func (c *conn) Set(p string, data []byte, version int32) (*zk.Stat, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	_, ok := c.nodes[p]
+	if !ok {
+		return nil, zk.ErrNoNode
+	}
+	c.nodes[p] = data
+	c.notifyData(p, zk.EventNodeDataChanged)
+	return &zk.Stat{}, nil
+}
+
+func (c *conn) Delete(p string, version int32) error {
+	c.l.Lock()
+	defer c.l.Unlock()
+	_, ok := c.nodes[p]
+	if !ok {
+		return zk.ErrNoNode
+	}
+	delete(c.nodes, p)
+	c.notify(p)
+	c.notifyData(p, zk.EventNodeDeleted)
+	return nil
+}
+
+func (c *conn) Exists(p string) (bool, *zk.Stat, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	_, ok := c.nodes[p]
+	return ok, &zk.Stat{}, nil
+}
+
+func (c *conn) Get(p string) ([]byte, *zk.Stat, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	data, ok := c.nodes[p]
+	if !ok {
+		return nil, nil, zk.ErrNoNode
+	}
+	return data, &zk.Stat{}, nil
+}
+
+func (c *conn) GetW(p string) ([]byte, *zk.Stat, <-chan zk.Event, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	data, ok := c.nodes[p]
+	if !ok {
+		return nil, nil, nil, zk.ErrNoNode
+	}
+	ch := make(chan zk.Event, 1)
+	c.data[p] = append(c.data[p], ch)
+	return data, &zk.Stat{}, ch, nil
+}
+
+func (c *conn) ChildrenW(p string) ([]string, *zk.Stat, <-chan zk.Event, error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	var children []string
+	for k := range c.nodes {
+		if path.Dir(k) == p {
+			children = append(children, strings.TrimPrefix(k, p+"/"))
+		}
+	}
+	ch := make(chan zk.Event, 1)
+	c.watches[p] = append(c.watches[p], ch)
+	return children, &zk.Stat{}, ch, nil
+}
+
+// expire simulates the ephemeral nodes removed after session expiry
+func (c *conn) expire(p string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	delete(c.nodes, p)
+	c.notify(p)
+	c.notifyData(p, zk.EventNodeDeleted)
+}
+
+func TestZookeeperRegistry(t *testing.T) {
+	c := newConn()
+	r := NewRegistry(c, Interval(50*time.Millisecond))
+	ctx := context.Background()
+	w, err := r.Discover(ctx, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := w.Next(ctx)
+	if err != nil || len(e.Services) != 0 {
+		t.Fatalf("initial snapshot:%+v, error:%+v", e, err)
+	}
+
+	svc := &registry.Service{
+		ID:       "1",
+		Name:     "test",
+		Endpoint: []string{"grpc://127.0.0.1:9090"},
+		Metadata: map[string]string{"weight": "50"},
+	}
+	err = r.Register(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Added) != 1 || e.Added[0].Metadata["weight"] != "50" {
+		t.Fatalf("register event:%+v, error:%+v", e, err)
+	}
+
+	// the metadata updated in place
+	svc.Metadata = map[string]string{"weight": "80"}
+	err = r.Register(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Updated) != 1 || e.Updated[0].Metadata["weight"] != "80" {
+		t.Fatalf("update event:%+v, error:%+v", e, err)
+	}
+	// a data watch per node
+	c.l.Lock()
+	n := len(c.data["/slark/test/1"])
+	c.l.Unlock()
+	if n != 1 {
+		t.Fatalf("data watches:%d", n)
+	}
+	// a children watch per directory
+	c.l.Lock()
+	n = len(c.watches["/slark/test"])
+	c.l.Unlock()
+	if n != 1 {
+		t.Fatalf("children watches:%d", n)
+	}
+
+	// recreated by keepalive
+	c.expire("/slark/test/1")
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Removed) != 1 {
+		t.Fatalf("expire event:%+v, error:%+v", e, err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Added) != 1 {
+		t.Fatalf("keepalive event:%+v, error:%+v", e, err)
+	}
+
+	err = r.Unregister(ctx, svc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err = w.Next(ctx)
+	if err != nil || len(e.Services) != 0 {
+		t.Fatalf("unregister event:%+v, error:%+v", e, err)
+	}
+
+	_ = w.Stop()
+	_, err = w.Next(ctx)
+	if err != registry.ErrWatcherStopped {
+		t.Fatalf("stop error:%+v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// forcePick is the max time a node is not picked, so that the stats of a node penalized once are refreshed
+const forcePick = 3 * time.Second
+
+type p2c struct {
+	l sync.Mutex
+	r *rand.Rand
+}
+
+type p2cBuilder struct{}
+
+// NewP2CBuilder picks the heavier of two random nodes weighted by node.EWMA, it is plugged in by balancer.SetBuilder
+func NewP2CBuilder() node.Builder {
+	return &p2cBuilder{}
+}
+
+// Build keeps the stats per balancer, so that the nodes saved by a balancer prune the stats of its own
+func (b *p2cBuilder) Build() node.Balancer {
+	return (&node.BalancerBuilder{
+		Picker:          &p2c{r: rand.New(rand.NewSource(time.Now().UnixNano()))},
+		WeightedBuilder: node.NewEWMA(),
+	}).Build()
+}
+
+func (p *p2c) Pick(_ context.Context, nodes []node.WeightedNode) (node.WeightedNode, error) {
+	switch len(nodes) {
+	case 0:
+		return nil, errors.New("no available node")
+	case 1:
+		return nodes[0], nil
+	}
+	p.l.Lock()
+	a := p.r.Intn(len(nodes))
+	b := p.r.Intn(len(nodes) - 1)
+	p.l.Unlock()
+	if b >= a {
+		b++
+	}
+	pc, uc := nodes[a], nodes[b]
+	if uc.Weight() > pc.Weight() {
+		pc, uc = uc, pc
+	}
+	if e, ok := uc.(interface{ PickElapsed() time.Duration }); ok && e.PickElapsed() > forcePick {
+		pc = uc
+	}
+	return pc, nil
+}
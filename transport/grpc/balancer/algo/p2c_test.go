@@ -0,0 +1,92 @@
+package algo
+
+import (
+	"context"
+	"fmt"
+	utils "github.com/go-slark/slark/pkg"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"google.golang.org/grpc/metadata"
+	"sync"
+	"testing"
+	"time"
+)
+
+func nodes(n int) []node.Node {
+	ns := make([]node.Node, 0, n)
+	for i := 0; i < n; i++ {
+		ns = append(ns, &node.WrappedNode{Addr: fmt.Sprintf("127.0.0.1:%d", 9000+i)})
+	}
+	return ns
+}
+
+// simulate sends the requests concurrently, the rpc on a node takes latency(addr), it returns the picks per node
+func simulate(t *testing.T, b node.Balancer, requests, concurrency int, rpc func(addr string) node.DoneInfo) map[string]int {
+	picks := map[string]int{}
+	l := sync.Mutex{}
+	ch := make(chan struct{}, requests)
+	for i := 0; i < requests; i++ {
+		ch <- struct{}{}
+	}
+	close(ch)
+	wg := sync.WaitGroup{}
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range ch {
+				n, err := b.Pick(context.Background())
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				done := n.(node.Feedback).Pick()
+				di := rpc(n.Address())
+				done(context.Background(), di)
+				l.Lock()
+				picks[n.Address()]++
+				l.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return picks
+}
+
+func TestP2CLatency(t *testing.T) {
+	b := NewP2CBuilder().Build()
+	ns := nodes(3)
+	b.Save(ns)
+	slow := ns[0].Address()
+	picks := simulate(t, b, 1200, 6, func(addr string) node.DoneInfo {
+		if addr == slow {
+			time.Sleep(20 * time.Millisecond)
+		} else {
+			time.Sleep(time.Millisecond)
+		}
+		return node.DoneInfo{}
+	})
+	t.Logf("picks:%v", picks)
+	// random is 1/3 of the traffic
+	if picks[slow]*10 > 1200 {
+		t.Fatalf("traffic not shifted from the slow node, picks:%v", picks)
+	}
+}
+
+func TestP2CServerLoad(t *testing.T) {
+	b := NewP2CBuilder().Build()
+	ns := nodes(2)
+	b.Save(ns)
+	busy := ns[0].Address()
+	picks := simulate(t, b, 600, 4, func(addr string) node.DoneInfo {
+		time.Sleep(time.Millisecond)
+		cpu := "0"
+		if addr == busy {
+			cpu = "900"
+		}
+		return node.DoneInfo{Trailer: metadata.Pairs(utils.CPUUsage, cpu)}
+	})
+	t.Logf("picks:%v", picks)
+	if picks[busy] >= picks[ns[1].Address()] {
+		t.Fatalf("traffic not shifted from the busy node, picks:%v", picks)
+	}
+}
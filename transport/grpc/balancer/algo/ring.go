@@ -0,0 +1,140 @@
+package algo
+
+import (
+	"context"
+	"errors"
+	"github.com/go-slark/slark/pkg/hash"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"google.golang.org/grpc/metadata"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+type hashKey struct{}
+
+// NewHashKeyContext sets the hash key of the ring hash balancing, such as a user id or a session id
+func NewHashKeyContext(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, hashKey{}, key)
+}
+
+func HashKeyFromContext(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(hashKey{}).(string)
+	return key, ok
+}
+
+type ringBuilder struct {
+	header string
+	vn     int
+}
+
+type RingOption func(*ringBuilder)
+
+// HashHeader takes the hash key from the outgoing metadata header if the context has no hash key
+func HashHeader(header string) RingOption {
+	return func(b *ringBuilder) {
+		b.header = header
+	}
+}
+
+// VirtualNodes :virtual nodes per node on the ring
+func VirtualNodes(vn int) RingOption {
+	return func(b *ringBuilder) {
+		b.vn = vn
+	}
+}
+
+// NewRingHashBuilder picks the node of the hash key on a consistent hash ring, the ring is saved incrementally as the addresses change,
+// a key falls back to the next node on the ring if its node is filtered out, and to a random node if the request has no key
+func NewRingHashBuilder(opts ...RingOption) node.Builder {
+	b := &ringBuilder{vn: 160}
+	for _, opt := range opts {
+		opt(b)
+	}
+	return b
+}
+
+func (b *ringBuilder) Build() node.Balancer {
+	return &ring{
+		ring:   hash.New(hash.VirtualNodes(b.vn)),
+		nodes:  map[string]node.Node{},
+		header: b.header,
+		r:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+type ring struct {
+	ring   *hash.Consistent
+	nodes  map[string]node.Node
+	header string
+	l      sync.RWMutex
+	r      *rand.Rand
+	rl     sync.Mutex
+}
+
+func (r *ring) Save(nodes []node.Node) {
+	mp := make(map[string]node.Node, len(nodes))
+	for _, n := range nodes {
+		mp[n.Address()] = n
+	}
+	r.l.Lock()
+	defer r.l.Unlock()
+	for addr := range r.nodes {
+		if _, ok := mp[addr]; !ok {
+			r.ring.Delete(addr)
+		}
+	}
+	for addr := range mp {
+		if _, ok := r.nodes[addr]; !ok {
+			r.ring.Add(addr)
+		}
+	}
+	r.nodes = mp
+}
+
+func (r *ring) key(ctx context.Context) string {
+	key, ok := HashKeyFromContext(ctx)
+	if ok || len(r.header) == 0 {
+		return key
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if v := md.Get(r.header); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (r *ring) Pick(ctx context.Context, filters ...node.Filter) (node.Node, error) {
+	r.l.RLock()
+	ns := make([]node.Node, 0, len(r.nodes))
+	for _, n := range r.nodes {
+		ns = append(ns, n)
+	}
+	r.l.RUnlock()
+	for _, filter := range filters {
+		ns = filter(ctx, ns)
+	}
+	if len(ns) == 0 {
+		return nil, errors.New("no available node")
+	}
+	key := r.key(ctx)
+	if len(key) == 0 {
+		r.rl.Lock()
+		i := r.r.Intn(len(ns))
+		r.rl.Unlock()
+		return ns[i], nil
+	}
+	available := make(map[string]node.Node, len(ns))
+	for _, n := range ns {
+		available[n.Address()] = n
+	}
+	addr := r.ring.FetchFunc(key, func(addr string) bool {
+		_, ok := available[addr]
+		return ok
+	})
+	n, ok := available[addr]
+	if !ok {
+		return nil, errors.New("no available node")
+	}
+	return n, nil
+}
@@ -0,0 +1,91 @@
+package algo
+
+import (
+	"context"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"google.golang.org/grpc/metadata"
+	"strconv"
+	"testing"
+)
+
+func pick(t *testing.T, b node.Balancer, ctx context.Context, filters ...node.Filter) string {
+	n, err := b.Pick(ctx, filters...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n.Address()
+}
+
+func TestRingHash(t *testing.T) {
+	b := NewRingHashBuilder(HashHeader("x-user-id")).Build()
+	ns := nodes(4)
+	b.Save(ns[:3])
+
+	const keys = 1000
+	picked := make(map[string]string, keys)
+	for i := 0; i < keys; i++ {
+		key := strconv.Itoa(i)
+		picked[key] = pick(t, b, NewHashKeyContext(context.Background(), key))
+		if pick(t, b, NewHashKeyContext(context.Background(), key)) != picked[key] {
+			t.Fatalf("key:%s not sticky", key)
+		}
+	}
+	// header
+	ctx := metadata.NewOutgoingContext(context.Background(), metadata.Pairs("x-user-id", "7"))
+	if pick(t, b, ctx) != picked["7"] {
+		t.Fatal("header key not hashed")
+	}
+
+	// the ring is saved incrementally, the keys move to the added node only
+	b.Save(ns)
+	moved := 0
+	for key, addr := range picked {
+		cur := pick(t, b, NewHashKeyContext(context.Background(), key))
+		if cur != addr {
+			if cur != ns[3].Address() {
+				t.Fatalf("key:%s moved from %s to %s", key, addr, cur)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > keys/2 {
+		t.Fatalf("moved:%d", moved)
+	}
+
+	// fall back to the next node on the ring if the node is filtered out
+	b.Save(ns[:3])
+	unavailable := ns[0].Address()
+	filter := func(_ context.Context, nodes []node.Node) []node.Node {
+		available := make([]node.Node, 0, len(nodes))
+		for _, n := range nodes {
+			if n.Address() != unavailable {
+				available = append(available, n)
+			}
+		}
+		return available
+	}
+	fallback := map[string]string{}
+	for key, addr := range picked {
+		cur := pick(t, b, NewHashKeyContext(context.Background(), key), filter)
+		if cur == unavailable || (addr != unavailable && cur != addr) {
+			t.Fatalf("key:%s picked %s, hashed %s", key, cur, addr)
+		}
+		fallback[key] = cur
+	}
+	// the same as the ring without the node
+	b.Save(ns[1:3])
+	for key, addr := range fallback {
+		if cur := pick(t, b, NewHashKeyContext(context.Background(), key)); cur != addr {
+			t.Fatalf("key:%s fell back to %s, ring %s", key, addr, cur)
+		}
+	}
+
+	// no key
+	if len(pick(t, b, context.Background())) == 0 {
+		t.Fatal("no node picked without key")
+	}
+	b.Save(nil)
+	if _, err := b.Pick(context.Background()); err == nil {
+		t.Fatal("picked from no node")
+	}
+}
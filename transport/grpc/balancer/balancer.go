@@ -7,6 +7,7 @@ import (
 	"github.com/go-slark/slark/transport/grpc"
 	"github.com/go-slark/slark/transport/grpc/balancer/algo"
 	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"github.com/go-slark/slark/transport/grpc/balancer/outlier"
 	"google.golang.org/grpc/balancer"
 	"google.golang.org/grpc/balancer/base"
 	"strconv"
@@ -14,22 +15,33 @@ import (
 
 const LoadBalancer = "load_balancer"
 
-var pickerBuilder = &builder{Builder: algo.NewWRRBuilder()}
+// pickerBuilder detects the outliers of the wrr by default
+var pickerBuilder = &builder{Builder: outlier.New(algo.NewWRRBuilder())}
 
+// SetBuilder replaces the default builder, wrap it by outlier.New to keep the outlier detection
 func SetBuilder(builder node.Builder) {
 	pickerBuilder.Builder = builder
 }
 
 func init() {
-	balancer.Register(base.NewBalancerBuilder(
-		LoadBalancer,
-		pickerBuilder,
-		base.Config{HealthCheck: true},
-	))
+	balancer.Register(&balancerBuilder{})
+}
+
+// balancerBuilder builds the picker builder per ClientConn, so that the balancer of the conn is saved incrementally
+type balancerBuilder struct{}
+
+func (b *balancerBuilder) Name() string {
+	return LoadBalancer
+}
+
+func (b *balancerBuilder) Build(cc balancer.ClientConn, opts balancer.BuildOptions) balancer.Balancer {
+	pb := &builder{Builder: pickerBuilder.Builder}
+	return base.NewBalancerBuilder(LoadBalancer, pb, base.Config{HealthCheck: true}).Build(cc, opts)
 }
 
 type builder struct {
 	node.Builder
+	balancer node.Balancer
 }
 
 func (b *builder) Build(info base.PickerBuildInfo) balancer.Picker {
@@ -44,6 +56,7 @@ func (b *builder) Build(info base.PickerBuildInfo) balancer.Picker {
 			SubConn: sc,
 		}
 		if ok {
+			n.Service = svc
 			w, o := svc.Metadata[utils.Weight]
 			if o {
 				weight, err := strconv.ParseInt(w, 10, 64)
@@ -54,11 +67,11 @@ func (b *builder) Build(info base.PickerBuildInfo) balancer.Picker {
 		}
 		nodes = append(nodes, n)
 	}
-	p := &picker{
-		Balancer: b.Builder.Build(),
+	if b.balancer == nil {
+		b.balancer = b.Builder.Build()
 	}
-	p.Save(nodes)
-	return p
+	b.balancer.Save(nodes)
+	return &picker{Balancer: b.balancer}
 }
 
 type picker struct {
@@ -78,9 +91,23 @@ func (p *picker) Pick(info balancer.PickInfo) (balancer.PickResult, error) {
 	if err != nil {
 		return balancer.PickResult{}, err
 	}
+	var done node.DoneFunc
+	if f, o := n.(node.Feedback); o {
+		done = f.Pick()
+	}
 	result := balancer.PickResult{
-		SubConn: n.(*node.WrappedNode).SubConn,
-		Done:    func(info balancer.DoneInfo) {},
+		SubConn: node.Unwrap(n).(*node.WrappedNode).SubConn,
+		Done: func(di balancer.DoneInfo) {
+			if done == nil {
+				return
+			}
+			done(info.Ctx, node.DoneInfo{
+				Err:           di.Err,
+				Trailer:       di.Trailer,
+				BytesSent:     di.BytesSent,
+				BytesReceived: di.BytesReceived,
+			})
+		},
 	}
 	return result, nil
 }
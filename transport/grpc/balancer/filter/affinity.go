@@ -0,0 +1,43 @@
+package filter
+
+import (
+	"context"
+	utils "github.com/go-slark/slark/pkg"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+)
+
+// Affinity prefers the nodes whose metadata key is value, and spills over to all the nodes when the preferred healthy nodes
+// are less than ratio of their fair share, the fair share is the healthy nodes divided by the distinct values of key,
+// such as 3 zones of 2 healthy nodes each, the local zone spills over with ratio 0.5 once it has no healthy node left,
+// and with ratio 1 once it has a node less than the others
+func Affinity(key, value string, ratio float64) node.Filter {
+	return func(_ context.Context, nodes []node.Node) []node.Node {
+		if len(nodes) == 0 {
+			return nodes
+		}
+		values := map[string]struct{}{}
+		preferred := make([]node.Node, 0, len(nodes))
+		for _, n := range nodes {
+			v := node.Metadata(n)[key]
+			values[v] = struct{}{}
+			if v == value {
+				preferred = append(preferred, n)
+			}
+		}
+		share := float64(len(nodes)) / float64(len(values))
+		if len(preferred) == 0 || float64(len(preferred)) < ratio*share {
+			return nodes
+		}
+		return preferred
+	}
+}
+
+// Zone prefers the nodes of the zone in the metadata of the registered service, see Affinity
+func Zone(zone string, ratio float64) node.Filter {
+	return Affinity(utils.Zone, zone, ratio)
+}
+
+// Region prefers the nodes of the region in the metadata of the registered service, see Affinity
+func Region(region string, ratio float64) node.Filter {
+	return Affinity(utils.Region, region, ratio)
+}
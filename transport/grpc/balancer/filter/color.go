@@ -0,0 +1,49 @@
+package filter
+
+import (
+	"context"
+	utils "github.com/go-slark/slark/pkg"
+	md "github.com/go-slark/slark/pkg/metadata"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"google.golang.org/grpc/metadata"
+)
+
+// Color routes the request of x-md-color to the nodes of the same color, and to the uncolored nodes if none of the color,
+// the request of no color goes to the uncolored nodes, and to all the nodes if all colored,
+// the color is taken from the outgoing metadata, or the metadata of the inbound request propagated by the metadata middleware,
+// which is enabled by Enable(0xe3) of the server and WithEnable(0x23) of the client
+func Color() node.Filter {
+	return func(ctx context.Context, nodes []node.Node) []node.Node {
+		color := fromContext(ctx)
+		colored := make([]node.Node, 0, len(nodes))
+		plain := make([]node.Node, 0, len(nodes))
+		for _, n := range nodes {
+			c := node.Metadata(n)[utils.Color]
+			switch {
+			case len(c) == 0:
+				plain = append(plain, n)
+			case c == color:
+				colored = append(colored, n)
+			}
+		}
+		if len(colored) > 0 {
+			return colored
+		}
+		if len(plain) == 0 && len(color) == 0 {
+			return nodes
+		}
+		return plain
+	}
+}
+
+func fromContext(ctx context.Context) string {
+	out, _ := metadata.FromOutgoingContext(ctx)
+	if v := out.Get(md.Color); len(v) > 0 {
+		return v[0]
+	}
+	m, _ := md.FromMetadataContext(ctx)
+	if v := m[md.Color]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
@@ -0,0 +1,120 @@
+package filter
+
+import (
+	"context"
+	"github.com/go-slark/slark/pkg/metadata"
+	"github.com/go-slark/slark/registry"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	gmd "google.golang.org/grpc/metadata"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func newNode(addr, version string, md map[string]string) node.Node {
+	return &node.WrappedNode{Addr: addr, Service: &registry.Service{Version: version, Metadata: md}}
+}
+
+func addrs(nodes []node.Node) []string {
+	as := make([]string, 0, len(nodes))
+	for _, n := range nodes {
+		as = append(as, n.Address())
+	}
+	sort.Strings(as)
+	return as
+}
+
+func assert(t *testing.T, name string, nodes []node.Node, want ...string) {
+	t.Helper()
+	if got := addrs(nodes); !reflect.DeepEqual(got, want) && !(len(got) == 0 && len(want) == 0) {
+		t.Fatalf("%s: got %v, want %v", name, got, want)
+	}
+}
+
+func TestZone(t *testing.T) {
+	a1 := newNode("a1", "", map[string]string{"zone": "a"})
+	a2 := newNode("a2", "", map[string]string{"zone": "a"})
+	b1 := newNode("b1", "", map[string]string{"zone": "b"})
+	b2 := newNode("b2", "", map[string]string{"zone": "b"})
+	ctx := context.Background()
+
+	assert(t, "local", Zone("a", 0.5)(ctx, []node.Node{a1, a2, b1, b2}), "a1", "a2")
+	// a node of zone a is unhealthy, 1 >= 0.5 * 3 / 2
+	assert(t, "degraded", Zone("a", 0.5)(ctx, []node.Node{a1, b1, b2}), "a1")
+	// 1 < 1 * 3 / 2
+	assert(t, "spillover", Zone("a", 1)(ctx, []node.Node{a1, b1, b2}), "a1", "b1", "b2")
+	assert(t, "down", Zone("a", 0)(ctx, []node.Node{b1, b2}), "b1", "b2")
+	assert(t, "region", Region("a", 0.5)(ctx, []node.Node{a1, b1}), "a1", "b1")
+}
+
+func TestVersion(t *testing.T) {
+	nodes := []node.Node{
+		newNode("v1", "v1.0.0", nil),
+		newNode("v1.2", "1.2.3", nil),
+		newNode("v1.3-rc", "1.3.0-rc.1", nil),
+		newNode("v1.3", "1.3.0", nil),
+		newNode("v2", "2.0.0", nil),
+		newNode("v0.2", "0.2.5", nil),
+		newNode("latest", "latest", nil),
+	}
+	ctx := context.Background()
+	assert(t, "exact", Version("latest", "2.0.0")(ctx, nodes), "latest", "v2")
+
+	cases := []struct {
+		constraint string
+		want       []string
+	}{
+		{"=1.2.3", []string{"v1.2"}},
+		{"v1.0.0", []string{"v1"}},
+		{"!=1.2.3, <2.0.0", []string{"v0.2", "v1", "v1.3", "v1.3-rc"}},
+		{">= 1.2.3 <1.3.0", []string{"v1.2", "v1.3-rc"}},
+		{">1.2.3", []string{"v1.3", "v1.3-rc", "v2"}},
+		{"<=1.2.3", []string{"v0.2", "v1", "v1.2"}},
+		{"<1.0.0 || >=2.0.0", []string{"v0.2", "v2"}},
+		{">1.3.0-alpha <1.3.0", []string{"v1.3-rc"}},
+		{">=1.3.0-rc.2", []string{"v1.3", "v2"}},
+	}
+	for _, c := range cases {
+		filter, err := Semver(c.constraint)
+		if err != nil {
+			t.Fatalf("%s: %v", c.constraint, err)
+		}
+		assert(t, c.constraint, filter(ctx, nodes), c.want...)
+	}
+	for _, constraint := range []string{"", "1.2.3.4", "1.x", "~1.2.3", "^1.2.3", ">1.2", ">=a", "1.2-rc", "1.2.3 ||"} {
+		if _, err := Semver(constraint); err == nil {
+			t.Fatalf("%q parsed", constraint)
+		}
+	}
+}
+
+func TestPrerelease(t *testing.T) {
+	// 1.0.0-alpha < 1.0.0-alpha.1 < 1.0.0-alpha.beta < 1.0.0-beta < 1.0.0-beta.2 < 1.0.0-beta.11 < 1.0.0-rc.1 < 1.0.0
+	versions := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-alpha.beta", "1.0.0-beta", "1.0.0-beta.2", "1.0.0-beta.11", "1.0.0-rc.1", "1.0.0"}
+	for i := 1; i < len(versions); i++ {
+		a, _ := parseVersion(versions[i-1])
+		b, _ := parseVersion(versions[i])
+		if a.Compare(*b) >= 0 || b.Compare(*a) <= 0 {
+			t.Fatalf("%s >= %s", versions[i-1], versions[i])
+		}
+	}
+}
+
+func TestColor(t *testing.T) {
+	plain := newNode("plain", "", nil)
+	blue := newNode("blue", "", map[string]string{"color": "blue"})
+	green := newNode("green", "", map[string]string{"color": "green"})
+	nodes := []node.Node{plain, blue, green}
+	filter := Color()
+
+	assert(t, "no color", filter(context.Background(), nodes), "plain")
+	assert(t, "all colored", filter(context.Background(), []node.Node{blue, green}), "blue", "green")
+
+	ctx := gmd.NewOutgoingContext(context.Background(), gmd.Pairs(metadata.Color, "blue"))
+	assert(t, "outgoing", filter(ctx, nodes), "blue")
+	ctx = metadata.NewMetadataContext(context.Background(), metadata.Metadata{metadata.Color: {"green"}})
+	assert(t, "propagated", filter(ctx, nodes), "green")
+	ctx = gmd.NewOutgoingContext(context.Background(), gmd.Pairs(metadata.Color, "red"))
+	assert(t, "fallback", filter(ctx, nodes), "plain")
+	assert(t, "none", filter(ctx, []node.Node{blue, green}))
+}
@@ -0,0 +1,135 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"github.com/coreos/go-semver/semver"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"strings"
+)
+
+// Version keeps the nodes of the versions
+func Version(versions ...string) node.Filter {
+	mp := make(map[string]struct{}, len(versions))
+	for _, v := range versions {
+		mp[v] = struct{}{}
+	}
+	return func(_ context.Context, nodes []node.Node) []node.Node {
+		filtered := make([]node.Node, 0, len(nodes))
+		for _, n := range nodes {
+			if _, ok := mp[node.Version(n)]; ok {
+				filtered = append(filtered, n)
+			}
+		}
+		return filtered
+	}
+}
+
+// Semver keeps the nodes whose semantic version satisfies the constraint, such as ">=1.2.0 <2.0.0", "=1.4.2 || >=2.0.0-rc.1",
+// the comparators of =, !=, >, >=, <, <= on the full versions are separated by space or comma, and joined by ||,
+// the nodes of no semantic version are filtered out
+func Semver(constraint string) (node.Filter, error) {
+	c, err := parseConstraint(constraint)
+	if err != nil {
+		return nil, err
+	}
+	return func(_ context.Context, nodes []node.Node) []node.Node {
+		filtered := make([]node.Node, 0, len(nodes))
+		for _, n := range nodes {
+			v, err := parseVersion(node.Version(n))
+			if err != nil {
+				continue
+			}
+			if c.match(v) {
+				filtered = append(filtered, n)
+			}
+		}
+		return filtered
+	}, nil
+}
+
+// parseVersion parses the version of X.Y.Z[-pre][+meta] prefixed by v optionally
+func parseVersion(s string) (*semver.Version, error) {
+	return semver.NewVersion(strings.TrimPrefix(s, "v"))
+}
+
+type comparator func(v *semver.Version) bool
+
+func parseComparator(s string) (comparator, error) {
+	op := ""
+	for _, o := range []string{">=", "<=", "!=", ">", "<", "="} {
+		if strings.HasPrefix(s, o) {
+			op = o
+			break
+		}
+	}
+	target, err := parseVersion(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+	var match func(n int) bool
+	switch op {
+	case "", "=":
+		match = func(n int) bool { return n == 0 }
+	case "!=":
+		match = func(n int) bool { return n != 0 }
+	case ">":
+		match = func(n int) bool { return n > 0 }
+	case ">=":
+		match = func(n int) bool { return n >= 0 }
+	case "<":
+		match = func(n int) bool { return n < 0 }
+	default:
+		match = func(n int) bool { return n <= 0 }
+	}
+	return func(v *semver.Version) bool {
+		return match(v.Compare(*target))
+	}, nil
+}
+
+// constraint is the union of the intersections of the comparators
+type constraint [][]comparator
+
+func parseConstraint(s string) (constraint, error) {
+	var c constraint
+	for _, or := range strings.Split(s, "||") {
+		var and []comparator
+		fields := strings.FieldsFunc(or, func(r rune) bool {
+			return r == ' ' || r == ','
+		})
+		// >= 1.2.0 is the same as >=1.2.0
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			if strings.Trim(f, "=!<>") == "" && i+1 < len(fields) {
+				i++
+				f += fields[i]
+			}
+			cmp, err := parseComparator(f)
+			if err != nil {
+				return nil, fmt.Errorf("invalid constraint %q: %w", s, err)
+			}
+			and = append(and, cmp)
+		}
+		if len(and) == 0 {
+			return nil, fmt.Errorf("invalid constraint %q", s)
+		}
+		c = append(c, and)
+	}
+	return c, nil
+}
+
+func (c constraint) match(v *semver.Version) bool {
+	for _, and := range c {
+		matched := true
+		for _, cmp := range and {
+			if !cmp(v) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
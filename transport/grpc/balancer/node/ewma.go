@@ -0,0 +1,131 @@
+package node
+
+import (
+	"context"
+	"github.com/go-slark/slark/errors"
+	utils "github.com/go-slark/slark/pkg"
+	"math"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// decay is the time constant of the ewma, the samples older than it weigh less than 1/e
+	decay = 10 * time.Second
+	// success is the success rate of a new node, in thousandths
+	success = 1000
+)
+
+// now is replaced by the tests
+var now = time.Now
+
+// EWMA builds the nodes weighted by the ewma latency, the in-flight requests, the ewma success rate
+// and the cpu usage reported by the server in the trailer, the stats are kept per address across the rebuilds of the picker,
+// and dropped once the address is not in the set saved, an EWMA is used by a balancer only
+type EWMA struct {
+	stats sync.Map
+}
+
+func NewEWMA() *EWMA {
+	return &EWMA{}
+}
+
+func (e *EWMA) Build(n Node) WeightedNode {
+	v, _ := e.stats.LoadOrStore(n.Address(), &stats{success: success, stamp: now().UnixNano()})
+	return &EWMANode{Node: n, stats: v.(*stats)}
+}
+
+// Prune drops the stats of the addresses not in the nodes
+func (e *EWMA) Prune(nodes []Node) {
+	mp := make(map[string]struct{}, len(nodes))
+	for _, n := range nodes {
+		mp[n.Address()] = struct{}{}
+	}
+	e.stats.Range(func(key, _ interface{}) bool {
+		if _, ok := mp[key.(string)]; !ok {
+			e.stats.Delete(key)
+		}
+		return true
+	})
+}
+
+type stats struct {
+	l        sync.Mutex
+	lag      float64 // ewma latency, ns
+	success  float64 // ewma success rate, in thousandths
+	cpu      int64   // cpu usage reported by the server, in thousandths
+	stamp    int64   // last done, unix nano
+	inflight int64
+	picked   int64 // last pick, unix nano
+}
+
+type EWMANode struct {
+	Node
+	stats *stats
+}
+
+func (n *EWMANode) Unwrap() Node {
+	return n.Node
+}
+
+// Weight is success / (sqrt(lag + 1) * (inflight + 1) * (1 + cpu)), scaled by the initial weight of 100 by default
+func (n *EWMANode) Weight() int64 {
+	s := n.stats
+	s.l.Lock()
+	lag, rate, cpu := s.lag, s.success, s.cpu
+	s.l.Unlock()
+	load := math.Sqrt(lag+1) * float64(atomic.LoadInt64(&s.inflight)+1) * (1 + float64(cpu)/1000)
+	weight := rate * 1e6 / load
+	if w := n.InitialWeight(); w != nil {
+		weight = weight * float64(*w) / 100
+	}
+	return int64(weight)
+}
+
+// PickElapsed is the time since the node was picked last
+func (n *EWMANode) PickElapsed() time.Duration {
+	return time.Duration(now().UnixNano() - atomic.LoadInt64(&n.stats.picked))
+}
+
+func (n *EWMANode) Pick() DoneFunc {
+	s := n.stats
+	start := now()
+	atomic.StoreInt64(&s.picked, start.UnixNano())
+	atomic.AddInt64(&s.inflight, 1)
+	return func(ctx context.Context, di DoneInfo) {
+		atomic.AddInt64(&s.inflight, -1)
+		end := now()
+		var rate float64 = success
+		if di.Err != nil && errors.FromError(di.Err).Code >= 500 {
+			rate = 0
+		}
+		cpu := int64(-1)
+		if v := di.Trailer.Get(utils.CPUUsage); len(v) > 0 {
+			c, err := strconv.ParseInt(v[0], 10, 64)
+			if err == nil && c >= 0 {
+				cpu = c
+			}
+		}
+
+		s.l.Lock()
+		defer s.l.Unlock()
+		td := end.UnixNano() - s.stamp
+		if td < 0 {
+			td = 0
+		}
+		s.stamp = end.UnixNano()
+		w := math.Exp(-float64(td) / float64(decay))
+		lw := w
+		if s.lag == 0 {
+			// the first sample
+			lw = 0
+		}
+		s.lag = s.lag*lw + float64(end.Sub(start))*(1-lw)
+		s.success = s.success*w + rate*(1-w)
+		if cpu >= 0 {
+			s.cpu = cpu
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package node
+
+import (
+	"context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+func (c *clock) rpc(n WeightedNode, lag time.Duration, err error) {
+	done := n.(Feedback).Pick()
+	c.t = c.t.Add(lag)
+	done(context.Background(), DoneInfo{Err: err})
+}
+
+func TestEWMA(t *testing.T) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	now = c.now
+	defer func() {
+		now = time.Now
+	}()
+	builder := NewEWMA()
+	fast := builder.Build(&WrappedNode{Addr: "127.0.0.1:9000"})
+	slow := builder.Build(&WrappedNode{Addr: "127.0.0.1:9001"})
+	c.rpc(fast, time.Millisecond, nil)
+	c.rpc(slow, 100*time.Millisecond, nil)
+	if fast.Weight() <= slow.Weight() {
+		t.Fatalf("latency not weighted, %d <= %d", fast.Weight(), slow.Weight())
+	}
+
+	// the slow node recovers as the old samples decay
+	for i := 0; i < 60; i++ {
+		c.t = c.t.Add(time.Second)
+		c.rpc(slow, time.Millisecond, nil)
+	}
+	if slow.Weight()*10 < fast.Weight()*8 {
+		t.Fatalf("latency not decayed, %d < %d", slow.Weight(), fast.Weight())
+	}
+
+	// failures over time
+	weight := slow.Weight()
+	for i := 0; i < 10; i++ {
+		c.t = c.t.Add(time.Second)
+		c.rpc(slow, time.Millisecond, status.Error(codes.Unavailable, "unavailable"))
+	}
+	if slow.Weight()*2 > weight {
+		t.Fatalf("failure not penalized, %d > %d / 2", slow.Weight(), weight)
+	}
+	// client errors are successes of the node
+	weight = fast.Weight()
+	c.rpc(fast, time.Millisecond, status.Error(codes.InvalidArgument, "invalid"))
+	if fast.Weight() != weight {
+		t.Fatalf("client error penalized, %d != %d", fast.Weight(), weight)
+	}
+
+	// in-flight and the stats kept across the rebuilds
+	done := fast.(Feedback).Pick()
+	rebuilt := builder.Build(&WrappedNode{Addr: "127.0.0.1:9000"})
+	if rebuilt.Weight()*2 > weight+1 || rebuilt.(*EWMANode).PickElapsed() != 0 {
+		t.Fatalf("in-flight not counted, %d", rebuilt.Weight())
+	}
+	done(context.Background(), DoneInfo{})
+	if rebuilt.Weight() != weight {
+		t.Fatalf("stats not kept, %d != %d", rebuilt.Weight(), weight)
+	}
+
+	// the stats of the nodes removed are dropped by the set saved
+	set := (&BalancerBuilder{WeightedBuilder: builder}).Build()
+	set.Save([]Node{&WrappedNode{Addr: "127.0.0.1:9000"}})
+	n := 0
+	builder.stats.Range(func(key, _ interface{}) bool {
+		n++
+		return key == "127.0.0.1:9000"
+	})
+	if n != 1 || builder.Build(&WrappedNode{Addr: "127.0.0.1:9000"}).Weight() != weight {
+		t.Fatalf("stats not pruned, %d", n)
+	}
+}
@@ -3,7 +3,9 @@ package node
 import (
 	"context"
 	"errors"
+	"github.com/go-slark/slark/registry"
 	"google.golang.org/grpc/balancer"
+	"google.golang.org/grpc/metadata"
 	"sync"
 )
 
@@ -11,6 +13,7 @@ type WrappedNode struct {
 	Addr    string
 	Weight  *int64
 	SubConn balancer.SubConn
+	Service *registry.Service
 }
 
 type Node interface {
@@ -18,6 +21,12 @@ type Node interface {
 	Address() string
 }
 
+// Registered is optionally implemented by Node of a registered service, such as *WrappedNode
+type Registered interface {
+	Version() string
+	Metadata() map[string]string
+}
+
 func (w *WrappedNode) Address() string {
 	return w.Addr
 }
@@ -26,12 +35,73 @@ func (w *WrappedNode) InitialWeight() *int64 {
 	return w.Weight
 }
 
+// Version is the version of the registered service of the node
+func (w *WrappedNode) Version() string {
+	if w.Service == nil {
+		return ""
+	}
+	return w.Service.Version
+}
+
+// Metadata is the metadata of the registered service of the node, such as zone / weight / color
+func (w *WrappedNode) Metadata() map[string]string {
+	if w.Service == nil {
+		return nil
+	}
+	return w.Service.Metadata
+}
+
 type WeightedNode interface {
 	Node
 	Weight() int64
 	//Unwrap() Node
 }
 
+// DoneInfo is the result of the rpc on the picked node, Trailer carries the load reported by the server
+type DoneInfo struct {
+	Err           error
+	Trailer       metadata.MD
+	BytesSent     bool
+	BytesReceived bool
+}
+
+type DoneFunc func(ctx context.Context, di DoneInfo)
+
+// Feedback is optionally implemented by WeightedNode, Pick is called once the node is picked,
+// and the returned DoneFunc is called once the rpc is done
+type Feedback interface {
+	Pick() DoneFunc
+}
+
+// Version returns the version of the registered service of the node unwrapped, empty if not registered
+func Version(n Node) string {
+	r, ok := Unwrap(n).(Registered)
+	if !ok {
+		return ""
+	}
+	return r.Version()
+}
+
+// Metadata returns the metadata of the registered service of the node unwrapped, nil if not registered
+func Metadata(n Node) map[string]string {
+	r, ok := Unwrap(n).(Registered)
+	if !ok {
+		return nil
+	}
+	return r.Metadata()
+}
+
+// Unwrap returns the node wrapped by the weighted nodes, such as *WrappedNode
+func Unwrap(n Node) Node {
+	for {
+		u, ok := n.(interface{ Unwrap() Node })
+		if !ok {
+			return n
+		}
+		n = u.Unwrap()
+	}
+}
+
 type Set struct {
 	nodes   []WeightedNode
 	l       sync.RWMutex
@@ -59,6 +129,9 @@ func (s *Set) Save(nodes []Node) {
 	for _, node := range nodes {
 		wn = append(wn, s.builder.Build(node))
 	}
+	if p, ok := s.builder.(Pruner); ok {
+		p.Prune(nodes)
+	}
 	s.l.Lock()
 	s.nodes = wn
 	s.l.Unlock()
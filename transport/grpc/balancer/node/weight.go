@@ -20,6 +20,11 @@ type WeightedBuilder interface {
 	Build(Node) WeightedNode
 }
 
+// Pruner is optionally implemented by WeightedBuilder, Prune drops the state kept of the nodes not in the set saved
+type Pruner interface {
+	Prune(nodes []Node)
+}
+
 type Plain struct{}
 
 func (p *Plain) Build(node Node) WeightedNode {
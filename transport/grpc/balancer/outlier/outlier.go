@@ -0,0 +1,331 @@
+package outlier
+
+import (
+	"context"
+	"github.com/go-slark/slark/logger"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	Consecutive = "consecutive_errors"
+	SuccessRate = "success_rate"
+)
+
+// now is replaced by the tests
+var now = time.Now
+
+// failures are the codes of the server failures, the errors of the client such as canceled, invalid argument, not found are not
+var failures = map[codes.Code]struct{}{
+	codes.Unknown:          {},
+	codes.DeadlineExceeded: {},
+	codes.Internal:         {},
+	codes.Unavailable:      {},
+	codes.DataLoss:         {},
+}
+
+func failure(err error) bool {
+	if err == nil {
+		return false
+	}
+	code := status.Code(err)
+	if code == codes.Unknown {
+		code = status.FromContextError(err).Code()
+	}
+	_, ok := failures[code]
+	return ok
+}
+
+type Builder struct {
+	builder      node.Builder
+	consecutive  int64
+	interval     time.Duration
+	stdev        float64
+	minRequests  int64
+	minHosts     int
+	maxPercent   int
+	baseEjection time.Duration
+	maxEjection  time.Duration
+	logger       logger.Logger
+	ejected      metric.Int64UpDownCounter
+	ejections    metric.Int64Counter
+}
+
+type Option func(*Builder)
+
+// ConsecutiveErrors ejects a node of n consecutive errors, 0 disables
+func ConsecutiveErrors(n int64) Option {
+	return func(b *Builder) {
+		b.consecutive = n
+	}
+}
+
+// Interval is the interval of the success rate detection
+func Interval(interval time.Duration) Option {
+	return func(b *Builder) {
+		b.interval = interval
+	}
+}
+
+// StdevFactor ejects a node whose success rate is below mean - factor * stdev of the success rates in an interval, 0 disables
+func StdevFactor(factor float64) Option {
+	return func(b *Builder) {
+		b.stdev = factor
+	}
+}
+
+// MinRequests is the min requests of a node in an interval to detect its success rate
+func MinRequests(n int64) Option {
+	return func(b *Builder) {
+		b.minRequests = n
+	}
+}
+
+// MinHosts is the min nodes of the min requests in an interval to detect the success rate
+func MinHosts(n int) Option {
+	return func(b *Builder) {
+		b.minHosts = n
+	}
+}
+
+// MaxEjectionPercent is the max percentage of the nodes ejected, at least a node can be ejected
+func MaxEjectionPercent(percent int) Option {
+	return func(b *Builder) {
+		b.maxPercent = percent
+	}
+}
+
+// EjectionTime is the ejection time of a node ejected first, doubled each time ejected again up to max
+func EjectionTime(base, max time.Duration) Option {
+	return func(b *Builder) {
+		b.baseEjection = base
+		b.maxEjection = max
+	}
+}
+
+func Logger(l logger.Logger) Option {
+	return func(b *Builder) {
+		b.logger = l
+	}
+}
+
+// New wraps the builder with the outlier detection, the nodes of consecutive errors or a success rate deviated from the others
+// are ejected from the picking for an exponential ejection time, the ejections are logged, and the ejected nodes are measured by
+// the meter of slark as the up down counter ejected_nodes and the counter ejection_count, only the server failures of unknown,
+// deadline exceeded, internal, unavailable, data loss are counted, the default builder of the balancer is wrapped by it
+func New(builder node.Builder, opts ...Option) node.Builder {
+	b := &Builder{
+		builder:      builder,
+		consecutive:  5,
+		interval:     10 * time.Second,
+		stdev:        1.9,
+		minRequests:  100,
+		minHosts:     5,
+		maxPercent:   10,
+		baseEjection: 30 * time.Second,
+		maxEjection:  300 * time.Second,
+		logger:       logger.GetLogger(),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	m := otel.Meter("slark")
+	b.ejected, _ = m.Int64UpDownCounter("ejected_nodes")
+	b.ejections, _ = m.Int64Counter("ejection_count")
+	return b
+}
+
+func (b *Builder) Build() node.Balancer {
+	return &detector{
+		Builder:  b,
+		balancer: b.builder.Build(),
+		stats:    map[string]*stats{},
+		sweep:    now(),
+	}
+}
+
+type stats struct {
+	consecutive int64
+	success     int64
+	failure     int64
+	ejections   int64     // the times ejected in a row
+	until       time.Time // ejected until
+	ejected     bool
+}
+
+type detector struct {
+	*Builder
+	balancer node.Balancer
+	l        sync.Mutex
+	stats    map[string]*stats
+	sweep    time.Time
+}
+
+func (d *detector) Save(nodes []node.Node) {
+	d.l.Lock()
+	mp := make(map[string]*stats, len(nodes))
+	for _, n := range nodes {
+		s, ok := d.stats[n.Address()]
+		if !ok {
+			s = &stats{}
+		}
+		mp[n.Address()] = s
+	}
+	for addr, s := range d.stats {
+		if _, ok := mp[addr]; !ok && s.ejected {
+			d.ejected.Add(context.Background(), -1, metric.WithAttributes(attribute.String("address", addr)))
+		}
+	}
+	d.stats = mp
+	d.l.Unlock()
+	d.balancer.Save(nodes)
+}
+
+func (d *detector) Pick(ctx context.Context, filters ...node.Filter) (node.Node, error) {
+	n, err := d.balancer.Pick(ctx, append([]node.Filter{d.filter}, filters...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &outlierNode{Node: n, d: d}, nil
+}
+
+// filter filters out the ejected nodes, and keeps all the nodes if all ejected
+func (d *detector) filter(ctx context.Context, nodes []node.Node) []node.Node {
+	t := now()
+	available := make([]node.Node, 0, len(nodes))
+	d.l.Lock()
+	for _, n := range nodes {
+		s, ok := d.stats[n.Address()]
+		if ok && s.ejected && t.After(s.until) {
+			s.ejected = false
+			d.ejected.Add(ctx, -1, metric.WithAttributes(attribute.String("address", n.Address())))
+			d.logger.Log(ctx, logger.InfoLevel, map[string]interface{}{"address": n.Address(), "ejections": s.ejections}, "node unejected")
+		}
+		if !ok || !s.ejected {
+			available = append(available, n)
+		}
+	}
+	d.l.Unlock()
+	if len(available) == 0 {
+		return nodes
+	}
+	return available
+}
+
+func (d *detector) done(ctx context.Context, addr string, err error) {
+	t := now()
+	d.l.Lock()
+	defer d.l.Unlock()
+	s, ok := d.stats[addr]
+	if !ok {
+		return
+	}
+	if failure(err) {
+		s.failure++
+		s.consecutive++
+		if d.consecutive > 0 && s.consecutive >= d.consecutive && !s.ejected {
+			d.eject(ctx, addr, s, t, Consecutive)
+		}
+	} else {
+		s.success++
+		s.consecutive = 0
+	}
+	if t.Sub(d.sweep) >= d.interval {
+		d.detect(ctx, t)
+	}
+}
+
+// detect ejects the nodes whose success rate in the interval is below mean - stdev * factor
+func (d *detector) detect(ctx context.Context, t time.Time) {
+	d.sweep = t
+	type rate struct {
+		addr string
+		s    *stats
+		rate float64
+	}
+	rates := make([]rate, 0, len(d.stats))
+	sum := 0.0
+	for addr, s := range d.stats {
+		total := s.success + s.failure
+		if total >= d.minRequests && total > 0 {
+			r := float64(s.success) / float64(total)
+			rates = append(rates, rate{addr: addr, s: s, rate: r})
+			sum += r
+		}
+		s.success, s.failure = 0, 0
+		// the ejection time backs off as the node keeps healthy
+		if !s.ejected && s.ejections > 0 && t.Sub(s.until) >= d.interval {
+			s.ejections--
+		}
+	}
+	if d.stdev <= 0 || len(rates) < d.minHosts || len(rates) == 0 {
+		return
+	}
+	mean := sum / float64(len(rates))
+	variance := 0.0
+	for _, r := range rates {
+		variance += (r.rate - mean) * (r.rate - mean)
+	}
+	threshold := mean - d.stdev*math.Sqrt(variance/float64(len(rates)))
+	for _, r := range rates {
+		if r.rate < threshold && !r.s.ejected {
+			d.eject(ctx, r.addr, r.s, t, SuccessRate)
+		}
+	}
+}
+
+func (d *detector) eject(ctx context.Context, addr string, s *stats, t time.Time, reason string) {
+	ejected := 0
+	for _, st := range d.stats {
+		if st.ejected && !t.After(st.until) {
+			ejected++
+		}
+	}
+	max := len(d.stats) * d.maxPercent / 100
+	if max < 1 {
+		max = 1
+	}
+	if ejected >= max {
+		return
+	}
+	s.ejections++
+	ejection := d.baseEjection << (s.ejections - 1)
+	if ejection > d.maxEjection || ejection <= 0 {
+		ejection = d.maxEjection
+	}
+	s.ejected = true
+	s.until = t.Add(ejection)
+	s.consecutive = 0
+	d.ejected.Add(ctx, 1, metric.WithAttributes(attribute.String("address", addr)))
+	d.ejections.Add(ctx, 1, metric.WithAttributes(attribute.String("address", addr), attribute.String("reason", reason)))
+	d.logger.Log(ctx, logger.WarnLevel, map[string]interface{}{"address": addr, "reason": reason, "ejections": s.ejections, "ejection": ejection.String()}, "node ejected")
+}
+
+type outlierNode struct {
+	node.Node
+	d *detector
+}
+
+func (n *outlierNode) Unwrap() node.Node {
+	return n.Node
+}
+
+func (n *outlierNode) Pick() node.DoneFunc {
+	var done node.DoneFunc
+	if f, ok := n.Node.(node.Feedback); ok {
+		done = f.Pick()
+	}
+	return func(ctx context.Context, di node.DoneInfo) {
+		n.d.done(ctx, n.Address(), di.Err)
+		if done != nil {
+			done(ctx, di)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package outlier
+
+import (
+	"context"
+	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/transport/grpc/balancer/algo"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	t time.Time
+}
+
+func (c *clock) now() time.Time {
+	return c.t
+}
+
+type events struct {
+	ejected, unejected int
+}
+
+func (e *events) Log(ctx context.Context, level uint, fields map[string]interface{}, v ...interface{}) {
+	switch v[0] {
+	case "node ejected":
+		e.ejected++
+	case "node unejected":
+		e.unejected++
+	}
+}
+
+func nodes(n int) []node.Node {
+	ns := make([]node.Node, 0, n)
+	for i := 0; i < n; i++ {
+		ns = append(ns, &node.WrappedNode{Addr: "127.0.0.1:" + strconv.Itoa(9000+i)})
+	}
+	return ns
+}
+
+// rpc picks a node, and fails the rpc if fail returns true of the node
+func rpc(t *testing.T, c *clock, b node.Balancer, fail func(addr string) bool) string {
+	n, err := b.Pick(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := n.(node.Feedback).Pick()
+	c.t = c.t.Add(time.Millisecond)
+	if fail(n.Address()) {
+		err = status.Error(codes.Unavailable, "unavailable")
+	}
+	done(context.Background(), node.DoneInfo{Err: err})
+	return n.Address()
+}
+
+func TestConsecutiveErrors(t *testing.T) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	now = c.now
+	defer func() {
+		now = time.Now
+	}()
+	e := &events{}
+	b := New(algo.NewWRRBuilder(), Logger(e), StdevFactor(0)).Build()
+	ns := nodes(3)
+	b.Save(ns)
+	bad := ns[0].Address()
+	failing := func(addr string) bool {
+		return addr == bad
+	}
+	for i := 0; i < 100; i++ {
+		rpc(t, c, b, failing)
+	}
+	if e.ejected != 1 {
+		t.Fatalf("ejected:%d", e.ejected)
+	}
+	// the node ejected is not picked in the ejection time
+	for i := 0; i < 100; i++ {
+		if rpc(t, c, b, failing) == bad {
+			t.Fatal("ejected node picked")
+		}
+	}
+	// the max ejection percent ejects a node at least, and the others are kept
+	for i := 0; i < 100; i++ {
+		rpc(t, c, b, func(string) bool { return true })
+	}
+	if e.ejected != 1 {
+		t.Fatalf("ejected over max ejection percent:%d", e.ejected)
+	}
+
+	// unejected after 30s, and ejected for 60s then
+	c.t = c.t.Add(30 * time.Second)
+	picked := false
+	for i := 0; i < 100; i++ {
+		if rpc(t, c, b, failing) == bad {
+			picked = true
+		}
+	}
+	if !picked || e.unejected != 1 || e.ejected != 2 {
+		t.Fatalf("picked:%v, unejected:%d, ejected:%d", picked, e.unejected, e.ejected)
+	}
+	c.t = c.t.Add(45 * time.Second)
+	for i := 0; i < 100; i++ {
+		if rpc(t, c, b, failing) == bad {
+			t.Fatal("ejection time not doubled")
+		}
+	}
+
+	// the stats are dropped with the node
+	b.Save(ns[1:])
+	b.Save(ns)
+	c.t = c.t.Add(time.Second)
+	if rpc(t, c, b, func(string) bool { return false }); e.ejected != 2 {
+		t.Fatalf("ejected:%d", e.ejected)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{status.Error(codes.Unavailable, "unavailable"), true},
+		{status.Error(codes.DeadlineExceeded, "deadline exceeded"), true},
+		{errors.New(500, "internal", "INTERNAL"), true},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{status.Error(codes.Canceled, "canceled"), false},
+		{status.Error(codes.InvalidArgument, "invalid argument"), false},
+		{errors.NotFound("not found", "NOT_FOUND"), false},
+		{errors.New(499, "client closed", "CLIENT_CLOSED"), false},
+	}
+	for _, c := range cases {
+		if failure(c.err) != c.want {
+			t.Fatalf("failure of %v:%v", c.err, !c.want)
+		}
+	}
+
+	// the client errors do not eject
+	e := &events{}
+	b := New(algo.NewWRRBuilder(), Logger(e), StdevFactor(0)).Build()
+	b.Save(nodes(3))
+	for i := 0; i < 100; i++ {
+		n, err := b.Pick(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		n.(node.Feedback).Pick()(context.Background(), node.DoneInfo{Err: status.Error(codes.Canceled, "canceled")})
+	}
+	if e.ejected != 0 {
+		t.Fatalf("ejected:%d", e.ejected)
+	}
+}
+
+func TestSuccessRate(t *testing.T) {
+	c := &clock{t: time.Unix(1700000000, 0)}
+	now = c.now
+	defer func() {
+		now = time.Now
+	}()
+	e := &events{}
+	b := New(algo.NewWRRBuilder(), Logger(e), ConsecutiveErrors(0), Interval(time.Second), MinRequests(10), MaxEjectionPercent(50)).Build()
+	ns := nodes(6)
+	b.Save(ns)
+	bad := ns[0].Address()
+	count := 0
+	// half of the rpcs of the node fail
+	failing := func(addr string) bool {
+		if addr != bad {
+			return false
+		}
+		count++
+		return count%2 == 0
+	}
+	for i := 0; i < 1100; i++ {
+		rpc(t, c, b, failing)
+	}
+	if e.ejected != 1 {
+		t.Fatalf("ejected:%d", e.ejected)
+	}
+	for i := 0; i < 100; i++ {
+		if rpc(t, c, b, failing) == bad {
+			t.Fatal("ejected node picked")
+		}
+	}
+	// the nodes of the same success rate are kept
+	for i := 0; i < 2000; i++ {
+		rpc(t, c, b, func(string) bool { return false })
+	}
+	if e.ejected != 1 {
+		t.Fatalf("ejected:%d", e.ejected)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/go-slark/slark/middleware"
 	"github.com/go-slark/slark/middleware/flexible/breaker"
 	"github.com/go-slark/slark/middleware/logging"
+	"github.com/go-slark/slark/middleware/metadata"
 	"github.com/go-slark/slark/middleware/metrics"
 	"github.com/go-slark/slark/middleware/recovery"
 	"github.com/go-slark/slark/middleware/tracing"
@@ -52,6 +53,7 @@ type option struct {
 	unary     []grpc.UnaryClientInterceptor
 	stream    []grpc.StreamClientInterceptor
 	mws       []middleware.Middleware
+	smws      []middleware.Middleware
 	discovery registry.Discovery
 	filters   []node.Filter
 }
@@ -112,12 +114,21 @@ func WithTLS(tls *tls.Config) Option {
 	}
 }
 
+// WithMiddleware sets the middlewares of the calls and the whole streams instead of the default,
+// the default stream middlewares are not used then unless set by WithStreamMiddleware
 func WithMiddleware(mws []middleware.Middleware) Option {
 	return func(o *option) {
 		o.mws = mws
 	}
 }
 
+// WithStreamMiddleware sets the middlewares observing each message of the streams, the middlewares of WithMiddleware observe the whole stream
+func WithStreamMiddleware(mws []middleware.Middleware) Option {
+	return func(o *option) {
+		o.smws = mws
+	}
+}
+
 func WithUnaryInterceptor(unary []grpc.UnaryClientInterceptor) Option {
 	return func(o *option) {
 		o.unary = unary
@@ -195,16 +206,30 @@ func Dial(ctx context.Context, opts ...Option) (*grpc.ClientConn, error) {
 		subset:   &resolver.Shuffle{},
 		enable:   0x03,
 	}
-	opt.mws = []middleware.Middleware{
-		tracing.Trace(trace.SpanKindClient),
-		logging.Log(middleware.Client, opt.logger),
-		metrics.Metrics(middleware.Client, metric.WithCounter(metric.RequestCodeCounter())),
-		breaker.Breaker(),
-		recovery.Recovery(opt.logger),
-	}
 	for _, o := range opts {
 		o(opt)
 	}
+	if opt.mws == nil {
+		opt.mws = []middleware.Middleware{
+			tracing.Trace(trace.SpanKindClient),
+			logging.Log(middleware.Client, opt.logger),
+			metrics.Metrics(middleware.Client, metric.WithCounter(metric.RequestCodeCounter())),
+			breaker.Breaker(),
+			recovery.Recovery(opt.logger),
+			metadata.Metadata(middleware.Client), // opt-in by the bit 0x20 of WithEnable
+		}
+		if opt.smws == nil {
+			// the streamable middlewares at the same positions
+			opt.smws = streamable([]middleware.Middleware{
+				tracing.Trace(trace.SpanKindClient),
+				logging.Log(middleware.Client, opt.logger),
+				metrics.Metrics(middleware.Client, metric.WithCounter(metric.RequestCodeCounter())),
+				nil,
+				nil,
+				nil,
+			}, opt.enable)
+		}
+	}
 	opt.mws = utils.Filter(opt.mws, opt.enable)
 	unary := []grpc.UnaryClientInterceptor{unaryClientInterceptor(opt)}
 	stream := []grpc.StreamClientInterceptor{streamClientInterceptor(opt)}
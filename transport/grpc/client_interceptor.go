@@ -5,7 +5,6 @@ import (
 	"errors"
 	"github.com/go-slark/slark/middleware"
 	utils "github.com/go-slark/slark/pkg"
-	tracing "github.com/go-slark/slark/pkg/opentelemetry/trace"
 	"github.com/go-slark/slark/transport"
 	"go.opentelemetry.io/otel/codes"
 	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
@@ -63,7 +62,7 @@ func streamClientInterceptor(opt *option) grpc.StreamClientInterceptor {
 			if err != nil {
 				return nil, err
 			}
-			cs := wrapClientStream(ctx, s, desc)
+			cs := wrapClientStream(ctx, s, desc, middleware.ComposeMiddleware(opt.smws...))
 
 			// trace
 			go func() {
@@ -82,8 +81,11 @@ func streamClientInterceptor(opt *option) grpc.StreamClientInterceptor {
 				}
 			}()
 			return cs, nil
-		})(ctx, nil)
-		return rsp.(grpc.ClientStream), err
+		})(ctx, &middleware.Stream{ClientStreams: desc.ClientStreams, ServerStreams: desc.ServerStreams})
+		if err != nil {
+			return nil, err
+		}
+		return rsp.(grpc.ClientStream), nil
 	}
 }
 
@@ -125,8 +127,11 @@ const (
 	errorEvent
 )
 
+// clientStreamWrapper passes the messages of the stream through the stream middlewares
 type clientStreamWrapper struct {
 	grpc.ClientStream
+	ctx      context.Context
+	mw       middleware.Middleware
 	rMsgID   int
 	sMsgID   int
 	finished chan error
@@ -135,7 +140,7 @@ type clientStreamWrapper struct {
 	events   chan streamEvent
 }
 
-func wrapClientStream(ctx context.Context, s grpc.ClientStream, desc *grpc.StreamDesc) *clientStreamWrapper {
+func wrapClientStream(ctx context.Context, s grpc.ClientStream, desc *grpc.StreamDesc, mw middleware.Middleware) *clientStreamWrapper {
 	events := make(chan streamEvent)
 	done := make(chan struct{})
 	finished := make(chan error)
@@ -162,6 +167,8 @@ func wrapClientStream(ctx context.Context, s grpc.ClientStream, desc *grpc.Strea
 
 	return &clientStreamWrapper{
 		ClientStream: s,
+		ctx:          ctx,
+		mw:           mw,
 		desc:         desc,
 		events:       events,
 		done:         done,
@@ -177,21 +184,25 @@ func (w *clientStreamWrapper) RecvMsg(m interface{}) error {
 		} else {
 			w.sendStreamEvent(errorEvent, err)
 		}
-	} else {
-		if !w.desc.ServerStreams {
-			w.sendStreamEvent(receiveEndEvent, nil)
-		} else {
-			w.rMsgID++
-			tracing.MessageReceived.Event(w.Context(), w.rMsgID, m)
-		}
+		return err
+	}
+	w.rMsgID++
+	ctx := middleware.NewMessageContext(w.ctx, &middleware.Message{Kind: middleware.Reply, ID: w.rMsgID})
+	_, err = w.mw(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})(ctx, m)
+	if !w.desc.ServerStreams {
+		w.sendStreamEvent(receiveEndEvent, nil)
 	}
 	return err
 }
 
 func (w *clientStreamWrapper) SendMsg(m interface{}) error {
 	w.sMsgID++
-	tracing.MessageSent.Event(w.Context(), w.sMsgID, m)
-	err := w.ClientStream.SendMsg(m)
+	ctx := middleware.NewMessageContext(w.ctx, &middleware.Message{Kind: middleware.Request, ID: w.sMsgID})
+	_, err := w.mw(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, w.ClientStream.SendMsg(req)
+	})(ctx, m)
 	if err != nil {
 		w.sendStreamEvent(errorEvent, err)
 	}
@@ -3,6 +3,7 @@ package resolver
 import (
 	"context"
 	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/logger"
 	utils "github.com/go-slark/slark/pkg"
 	"github.com/go-slark/slark/pkg/endpoint"
 	"github.com/go-slark/slark/registry"
@@ -30,21 +31,19 @@ func (p *parser) Close() {
 
 func (p *parser) watch() {
 	for {
-		select {
-		case <-p.ctx.Done():
-			return
-
-		default:
-			svc, err := p.watcher.List()
-			if err != nil {
-				if errors.Is(err, context.Canceled) {
-					return
-				}
-				time.Sleep(time.Second)
-				continue
+		event, err := p.watcher.Next(p.ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, registry.ErrWatcherStopped) {
+				return
 			}
-			p.update(svc)
+			select {
+			case <-p.ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
 		}
+		p.update(event.Services)
 	}
 }
 
@@ -78,8 +77,9 @@ func (p *parser) update(svc []*registry.Service) {
 		}
 		addresses = append(addresses, address)
 	}
+	// the deregistered addresses are dropped, the rpcs fail fast with no available node
 	if len(addresses) == 0 {
-		return
+		logger.Log(p.ctx, logger.WarnLevel, map[string]interface{}{"services": len(svc)}, "grpc resolver no available address")
 	}
 	_ = p.cc.UpdateState(resolver.State{Addresses: addresses})
 }
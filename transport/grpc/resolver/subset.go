@@ -1,8 +1,14 @@
 package resolver
 
 import (
+	"fmt"
 	"github.com/go-slark/slark/registry"
+	"hash/fnv"
 	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
 )
 
 type Subset interface {
@@ -20,3 +26,79 @@ func (s *Shuffle) Subset(set []*registry.Service, size int) []*registry.Service
 	}
 	return set[:size]
 }
+
+// Deterministic is the deterministic subsetting of the Google SRE book, the clients are grouped into rounds of
+// len(set) / size clients, the backends are permuted by the round, and each client of the round takes the subset of its own,
+// the backends are evenly loaded only if the client ids are sequential, such as the ordinals of a StatefulSet,
+// the permutation is ordered by the hash of the round and the backend, a backend added or removed changes a subset by a backend at most,
+// while a change of len(set) / size regroups the rounds and reshuffles every subset
+type Deterministic struct {
+	ClientID uint64
+}
+
+// NewDeterministic takes the ordinal suffix of the name as the client id, such as the pod name web-3 of a StatefulSet,
+// the hostname is taken if name is empty
+func NewDeterministic(name string) (*Deterministic, error) {
+	if len(name) == 0 {
+		var err error
+		name, err = os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+	}
+	ordinal, err := strconv.ParseUint(name[strings.LastIndex(name, "-")+1:], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("no ordinal of %q: %w", name, err)
+	}
+	return &Deterministic{ClientID: ordinal}, nil
+}
+
+func (d *Deterministic) Subset(set []*registry.Service, size int) []*registry.Service {
+	if len(set) <= size {
+		return set
+	}
+	count := uint64(len(set) / size)
+	round := d.ClientID / count
+	type backend struct {
+		svc *registry.Service
+		key uint64
+	}
+	backends := make([]backend, 0, len(set))
+	for _, svc := range set {
+		backends = append(backends, backend{svc: svc, key: permute(round, svc)})
+	}
+	sort.Slice(backends, func(i, j int) bool {
+		if backends[i].key != backends[j].key {
+			return backends[i].key < backends[j].key
+		}
+		return id(backends[i].svc) < id(backends[j].svc)
+	})
+	start := int(d.ClientID%count) * size
+	subset := make([]*registry.Service, 0, size)
+	for _, b := range backends[start : start+size] {
+		subset = append(subset, b.svc)
+	}
+	return subset
+}
+
+func permute(round uint64, svc *registry.Service) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(id(svc)))
+	return mix(h.Sum64() ^ mix(round))
+}
+
+// mix is the finalizer of splitmix64, the permutations of the rounds are uncorrelated
+func mix(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// id identifies the backend by the registered id, or the endpoints if no id
+func id(svc *registry.Service) string {
+	if len(svc.ID) > 0 {
+		return svc.ID
+	}
+	return strings.Join(svc.Endpoint, ",")
+}
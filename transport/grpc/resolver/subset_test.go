@@ -0,0 +1,134 @@
+package resolver
+
+import (
+	"github.com/go-slark/slark/registry"
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+func services(n int) []*registry.Service {
+	set := make([]*registry.Service, 0, n)
+	for i := 0; i < n; i++ {
+		set = append(set, &registry.Service{ID: "backend-" + strconv.Itoa(i), Endpoint: []string{"grpc://10.0.0." + strconv.Itoa(i) + ":9000"}})
+	}
+	return set
+}
+
+// load is the clients per backend
+func load(t *testing.T, clients []Subset, set []*registry.Service, size int) (int, int) {
+	mp := map[string]int{}
+	for _, c := range clients {
+		subset := c.Subset(set, size)
+		if len(subset) != size {
+			t.Fatalf("subset size:%d", len(subset))
+		}
+		for _, svc := range subset {
+			mp[svc.ID]++
+		}
+	}
+	min, max := len(clients), 0
+	for _, svc := range set {
+		if mp[svc.ID] < min {
+			min = mp[svc.ID]
+		}
+		if mp[svc.ID] > max {
+			max = mp[svc.ID]
+		}
+	}
+	return min, max
+}
+
+func TestDeterministicLoad(t *testing.T) {
+	const backends, size = 100, 10
+	set := services(backends)
+
+	// the clients of sequential ids load each backend evenly
+	clients := make([]Subset, 0, 300)
+	for i := 0; i < 300; i++ {
+		clients = append(clients, &Deterministic{ClientID: uint64(i)})
+	}
+	min, max := load(t, clients, set, size)
+	t.Logf("deterministic sequential ids, backend load min:%d max:%d", min, max)
+	if min != 30 || max != 30 {
+		t.Fatalf("uneven load, min:%d max:%d", min, max)
+	}
+	// the backends left over by the rounds are permuted, the load is near even
+	min, max = load(t, clients, services(backends+5), size)
+	t.Logf("deterministic sequential ids of %d backends, backend load min:%d max:%d", backends+5, min, max)
+	if max-min > 6 {
+		t.Fatalf("uneven load, min:%d max:%d", min, max)
+	}
+
+	// the clients of ids not sequential load the backends unevenly, but each backend is taken still
+	clients = clients[:0]
+	for i := 0; i < 300; i++ {
+		clients = append(clients, &Deterministic{ClientID: uint64(i * 2)})
+	}
+	min, max = load(t, clients, set, size)
+	t.Logf("deterministic even ids, backend load min:%d max:%d", min, max)
+	if min == 0 || max == 30 {
+		t.Fatalf("load, min:%d max:%d", min, max)
+	}
+}
+
+func TestNewDeterministic(t *testing.T) {
+	d, err := NewDeterministic("web-12")
+	if err != nil || d.ClientID != 12 {
+		t.Fatalf("client id:%+v, error:%+v", d, err)
+	}
+	for _, name := range []string{"web", "web-", "web-1a", "web-+1"} {
+		if _, err = NewDeterministic(name); err == nil {
+			t.Fatalf("%q parsed", name)
+		}
+	}
+}
+
+func TestDeterministicStable(t *testing.T) {
+	const size = 10
+	set := services(100)
+	d := &Deterministic{ClientID: 7}
+	subset := map[string]struct{}{}
+	for _, svc := range d.Subset(set, size) {
+		subset[svc.ID] = struct{}{}
+	}
+	diff := func(set []*registry.Service) int {
+		n := 0
+		for _, svc := range d.Subset(set, size) {
+			if _, ok := subset[svc.ID]; !ok {
+				n++
+			}
+		}
+		return n
+	}
+
+	// the same across the updates in any order
+	for i := 0; i < 10; i++ {
+		shuffled := append([]*registry.Service(nil), set...)
+		rand.Shuffle(len(shuffled), func(i, j int) {
+			shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+		})
+		if n := diff(shuffled); n != 0 {
+			t.Fatalf("subset changed by the order, %d", n)
+		}
+	}
+	// a backend added or removed changes the subset by a backend at most
+	if n := diff(append(services(100), &registry.Service{ID: "backend-new"})); n > 1 {
+		t.Fatalf("subset changed by an added backend, %d", n)
+	}
+	set = services(105)
+	subset = map[string]struct{}{}
+	for _, svc := range d.Subset(set, size) {
+		subset[svc.ID] = struct{}{}
+	}
+	for i := 0; i < len(set); i++ {
+		removed := append(append([]*registry.Service(nil), set[:i]...), set[i+1:]...)
+		if n := diff(removed); n > 1 {
+			t.Fatalf("subset changed by the removed backend %d, %d", i, n)
+		}
+	}
+	// the whole set if no more than the size
+	if len(d.Subset(set[:size], size)) != size {
+		t.Fatal("subset of small set")
+	}
+}
@@ -3,11 +3,13 @@ package grpc
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"github.com/go-slark/slark/logger"
 	"github.com/go-slark/slark/middleware"
 	"github.com/go-slark/slark/middleware/flexible/breaker"
 	"github.com/go-slark/slark/middleware/flexible/shedding"
 	"github.com/go-slark/slark/middleware/logging"
+	"github.com/go-slark/slark/middleware/metadata"
 	"github.com/go-slark/slark/middleware/metrics"
 	"github.com/go-slark/slark/middleware/recovery"
 	"github.com/go-slark/slark/middleware/tracing"
@@ -15,6 +17,7 @@ import (
 	utils "github.com/go-slark/slark/pkg"
 	"github.com/go-slark/slark/pkg/endpoint"
 	"github.com/go-slark/slark/pkg/opentelemetry/metric"
+	"github.com/go-slark/slark/transport/http/handler"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
@@ -23,7 +26,9 @@ import (
 	"google.golang.org/grpc/keepalive"
 	"google.golang.org/grpc/reflection"
 	"net"
+	"net/http"
 	"net/url"
+	"sync"
 	"time"
 )
 
@@ -39,9 +44,19 @@ type Server struct {
 	enable   int64
 	timeout  time.Duration
 	mws      []middleware.Middleware
+	smws     []middleware.Middleware
 	opts     []grpc.ServerOption
 	unary    []grpc.UnaryServerInterceptor
 	stream   []grpc.StreamServerInterceptor
+	ready    chan struct{}
+	once     sync.Once
+	base     context.Context
+	// grpc-web and connect
+	web         bool
+	cors        []handler.Option
+	webSrv      *http.Server
+	methods     map[string]struct{}
+	methodsOnce sync.Once
 }
 
 func NewServer(opts ...ServerOption) *Server {
@@ -52,19 +67,36 @@ func NewServer(opts ...ServerOption) *Server {
 		logger:  logger.GetLogger(),
 		opts:    ServerOpts(),
 		enable:  0x63,
-	}
-	srv.mws = []middleware.Middleware{
-		tracing.Trace(trace.SpanKindServer),
-		logging.Log(middleware.Server, srv.logger),
-		metrics.Metrics(middleware.Server, metric.WithHistogram(metric.RequestDurationHistogram())),
-		breaker.Breaker(),
-		shedding.Limit(),
-		recovery.Recovery(srv.logger),
-		validate.Validate(),
+		ready:   make(chan struct{}),
 	}
 	for _, o := range opts {
 		o(srv)
 	}
+	if srv.mws == nil {
+		srv.mws = []middleware.Middleware{
+			tracing.Trace(trace.SpanKindServer),
+			logging.Log(middleware.Server, srv.logger),
+			metrics.Metrics(middleware.Server, metric.WithHistogram(metric.RequestDurationHistogram())),
+			breaker.Breaker(),
+			shedding.Limit(),
+			recovery.Recovery(srv.logger),
+			validate.Validate(),
+			metadata.Metadata(middleware.Server), // opt-in by the bit 0x80 of Enable
+		}
+		if srv.smws == nil {
+			// the streamable middlewares at the same positions
+			srv.smws = streamable([]middleware.Middleware{
+				tracing.Trace(trace.SpanKindServer),
+				logging.Log(middleware.Server, srv.logger),
+				metrics.Metrics(middleware.Server, metric.WithHistogram(metric.RequestDurationHistogram())),
+				nil,
+				nil,
+				nil,
+				validate.Validate(),
+				nil,
+			}, srv.enable)
+		}
+	}
 	srv.mws = utils.Filter(srv.mws, srv.enable)
 	var grpcOpts []grpc.ServerOption
 	srv.unary = append(srv.unary, srv.unaryServerInterceptor())
@@ -83,6 +115,9 @@ func NewServer(opts ...ServerOption) *Server {
 	}
 
 	srv.Server = grpc.NewServer(grpcOpts...)
+	if srv.web {
+		srv.webSrv = &http.Server{Handler: handler.CORS(srv.cors...)(srv.WebHandler(http.NotFoundHandler())), TLSConfig: srv.tls.Clone()}
+	}
 	srv.err = srv.listen()
 	grpc_health_v1.RegisterHealthServer(srv.Server, srv.health)
 	reflection.Register(srv.Server)
@@ -94,16 +129,59 @@ func (s *Server) Start() error {
 		return s.err
 	}
 	s.health.Resume()
+	s.once.Do(func() {
+		close(s.ready)
+	})
+	if s.web {
+		return s.serveHTTP()
+	}
 	return s.Serve(s.listener)
 }
 
 func (s *Server) Stop(ctx context.Context) error {
 	s.health.Shutdown()
+	if s.web {
+		_ = s.listener.Close()
+	}
+	if s.webSrv != nil {
+		err := s.webSrv.Shutdown(ctx)
+		if err != nil {
+			return err
+		}
+	}
 	s.GracefulStop()
 	return nil
 }
 
+// SetBaseContext sets the base context of the requests served, it is called by the app before Start
+func (s *Server) SetBaseContext(ctx context.Context) {
+	s.base = ctx
+}
+
+// Ready waits for serving and checks the health status
+func (s *Server) Ready(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	rsp, err := s.health.Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	if rsp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		return fmt.Errorf("grpc server not serving:%s", rsp.Status)
+	}
+	return nil
+}
+
 func (s *Server) listen() error {
+	if s.listener != nil {
+		return nil
+	}
 	l, err := net.Listen(s.network, s.address)
 	if err != nil {
 		return err
@@ -186,12 +264,22 @@ func ServerOptions(opts []grpc.ServerOption) ServerOption {
 	}
 }
 
+// Middleware sets the middlewares of the calls and the whole streams instead of the default,
+// the default stream middlewares are not used then unless set by StreamMiddleware
 func Middleware(mws []middleware.Middleware) ServerOption {
 	return func(server *Server) {
 		server.mws = mws
 	}
 }
 
+// StreamMiddleware sets the middlewares observing each message of the streams, such as logging, metrics, tracing and validate,
+// the middlewares of Middleware observe the whole stream
+func StreamMiddleware(mws []middleware.Middleware) ServerOption {
+	return func(server *Server) {
+		server.smws = mws
+	}
+}
+
 type serverOpt struct {
 	maxConnectionIdle     time.Duration
 	maxConnectionAge      time.Duration
@@ -5,8 +5,8 @@ import (
 	"fmt"
 	"github.com/go-slark/slark/middleware"
 	utils "github.com/go-slark/slark/pkg"
-	"github.com/go-slark/slark/pkg/opentelemetry/trace"
 	"github.com/go-slark/slark/transport"
+	"github.com/zeromicro/go-zero/core/stat"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
@@ -29,7 +29,7 @@ func (s *Server) unaryServerInterceptor() grpc.UnaryServerInterceptor {
 			req:       Carrier(md),
 			rsp:       Carrier{},
 		}
-		ctx = transport.NewServerContext(ctx, trans)
+		ctx = transport.NewServerContext(transport.ValueContext(ctx, s.base), trans)
 		var cancel context.CancelFunc
 		if s.timeout > 0 {
 			ctx, cancel = context.WithTimeout(ctx, s.timeout)
@@ -49,13 +49,14 @@ func (s *Server) streamServerInterceptor() grpc.StreamServerInterceptor {
 			md = metadata.MD{}
 		}
 		trans := &Transport{
-			req: Carrier(md),
-			rsp: Carrier{},
+			operation: info.FullMethod,
+			req:       Carrier(md),
+			rsp:       Carrier{},
 		}
-		ctx = transport.NewServerContext(ctx, trans)
+		ctx = transport.NewServerContext(transport.ValueContext(ctx, s.base), trans)
 		_, err := middleware.ComposeMiddleware(s.mws...)(func(ctx context.Context, req interface{}) (interface{}, error) {
-			return nil, handler(srv, &ssWrapper{ctx: ctx, ServerStream: ss})
-		})(ctx, nil)
+			return nil, handler(srv, &ssWrapper{ctx: ctx, ServerStream: ss, mw: middleware.ComposeMiddleware(s.smws...)})
+		})(ctx, &middleware.Stream{ClientStreams: info.IsClientStream, ServerStreams: info.IsServerStream})
 		return err
 	}
 }
@@ -142,17 +143,32 @@ func ServerAuthZ() middleware.Middleware {
 	}
 }
 
+// ServerLoad reports the cpu usage in the trailer, so that the clients balanced by p2c learn the load of the server
+func ServerLoad() middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			_ = grpc.SetTrailer(ctx, metadata.Pairs(utils.CPUUsage, strconv.FormatInt(stat.CpuUsage(), 10)))
+			return handler(ctx, req)
+		}
+	}
+}
+
+// ssWrapper passes the messages of the stream through the stream middlewares
 type ssWrapper struct {
 	grpc.ServerStream
 	ctx    context.Context
+	mw     middleware.Middleware
 	rMsgID int // received msg id
 	sMsgID int // send msg id
 }
 
 func (w *ssWrapper) SendMsg(m interface{}) error {
 	w.sMsgID++
-	trace.MessageSent.Event(w.ctx, w.sMsgID, m)
-	return w.ServerStream.SendMsg(m)
+	ctx := middleware.NewMessageContext(w.ctx, &middleware.Message{Kind: middleware.Reply, ID: w.sMsgID})
+	_, err := w.mw(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, w.ServerStream.SendMsg(req)
+	})(ctx, m)
+	return err
 }
 
 func (w *ssWrapper) RecvMsg(m interface{}) error {
@@ -161,8 +177,11 @@ func (w *ssWrapper) RecvMsg(m interface{}) error {
 		return err
 	}
 	w.rMsgID++
-	trace.MessageReceived.Event(w.ctx, w.rMsgID, m)
-	return nil
+	ctx := middleware.NewMessageContext(w.ctx, &middleware.Message{Kind: middleware.Request, ID: w.rMsgID})
+	_, err = w.mw(func(ctx context.Context, req interface{}) (interface{}, error) {
+		return req, nil
+	})(ctx, m)
+	return err
 }
 
 func (w *ssWrapper) Context() context.Context {
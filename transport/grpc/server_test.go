@@ -4,3 +4,50 @@ package grpc
 go install github.com/bojand/ghz
 ghz --insecure --proto=test.proto --call=proto.test.Ping -d '{}' -c 90 -n 110  127.0.0.1:9090 (qps:90 请求110次)
 */
+
+import (
+	"context"
+	"github.com/go-slark/slark/middleware"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"testing"
+	"time"
+)
+
+type baseKey struct{}
+
+func TestBaseContext(t *testing.T) {
+	base, cancel := context.WithCancel(context.WithValue(context.Background(), baseKey{}, "app"))
+	cancel()
+	values := make(chan interface{}, 1)
+	srv := NewServer(
+		Address("127.0.0.1:0"),
+		Enable(1),
+		Middleware([]middleware.Middleware{func(handler middleware.Handler) middleware.Handler {
+			return func(ctx context.Context, req interface{}) (interface{}, error) {
+				values <- ctx.Value(baseKey{})
+				return handler(ctx, req)
+			}
+		}}),
+	)
+	srv.SetBaseContext(base)
+	go func() {
+		_ = srv.Start()
+	}()
+	defer srv.Stop(context.Background())
+
+	ctx, stop := context.WithTimeout(context.Background(), 5*time.Second)
+	defer stop()
+	conn, err := Dial(ctx, WithAddr(srv.listener.Addr().String()), WithEnable(0), WithStrategy(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the values of the base context are taken, the cancellation is not
+	_, err = grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := <-values; v != "app" {
+		t.Fatalf("base value:%v", v)
+	}
+}
@@ -0,0 +1,157 @@
+package grpc
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-slark/slark/middleware"
+	"github.com/go-slark/slark/transport"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"sync"
+	"testing"
+	"time"
+)
+
+type observer struct {
+	l      sync.Mutex
+	events []string
+}
+
+func (o *observer) middleware(pt middleware.PeerType) middleware.Middleware {
+	return func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			var trans transport.Transporter
+			if pt == middleware.Server {
+				trans, _ = transport.FromServerContext(ctx)
+			} else {
+				trans, _ = transport.FromClientContext(ctx)
+			}
+			event := fmt.Sprintf("%s %+v", trans.Operate(), req)
+			if msg, ok := middleware.MessageFromContext(ctx); ok {
+				event = fmt.Sprintf("%s %s %d %v", trans.Operate(), msg.Kind, msg.ID, req)
+			}
+			o.l.Lock()
+			o.events = append(o.events, event)
+			o.l.Unlock()
+			return handler(ctx, req)
+		}
+	}
+}
+
+func (o *observer) get() []string {
+	o.l.Lock()
+	defer o.l.Unlock()
+	return append([]string(nil), o.events...)
+}
+
+func TestStreamMiddleware(t *testing.T) {
+	svr, cli := &observer{}, &observer{}
+	srv := NewServer(
+		Address("127.0.0.1:0"),
+		Enable(1),
+		Middleware([]middleware.Middleware{svr.middleware(middleware.Server)}),
+		StreamMiddleware([]middleware.Middleware{svr.middleware(middleware.Server)}),
+	)
+	go func() {
+		_ = srv.Start()
+	}()
+	defer srv.Stop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx,
+		WithAddr(srv.listener.Addr().String()),
+		WithEnable(1),
+		// the client side health checking watches through the interceptors too
+		WithStrategy(nil),
+		WithMiddleware([]middleware.Middleware{cli.middleware(middleware.Client)}),
+		WithStreamMiddleware([]middleware.Middleware{cli.middleware(middleware.Client)}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cx, stop := context.WithCancel(ctx)
+	stream, err := grpc_health_v1.NewHealthClient(conn).Watch(cx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsp, err := stream.Recv()
+	if err != nil || rsp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("watch:%v, err:%v", rsp, err)
+	}
+	stop()
+
+	const op = "/grpc.health.v1.Health/Watch"
+	want := []string{op + " &{ClientStreams:false ServerStreams:true}", op + " request 1 ", op + " reply 1 status:SERVING"}
+	for _, o := range []*observer{cli, svr} {
+		var events []string
+		for i := 0; i < 100; i++ {
+			events = o.get()
+			if len(events) == len(want) {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		if fmt.Sprint(events) != fmt.Sprint(want) {
+			t.Fatalf("events:%q", events)
+		}
+	}
+}
+
+type logs struct {
+	l    sync.Mutex
+	msgs []string
+}
+
+func (l *logs) Log(ctx context.Context, level uint, fields map[string]interface{}, v ...interface{}) {
+	l.l.Lock()
+	defer l.l.Unlock()
+	l.msgs = append(l.msgs, fmt.Sprint(v...))
+}
+
+func (l *logs) count(msg string) int {
+	l.l.Lock()
+	defer l.l.Unlock()
+	n := 0
+	for _, m := range l.msgs {
+		if m == msg {
+			n++
+		}
+	}
+	return n
+}
+
+func TestStreamLogger(t *testing.T) {
+	svr, cli := &logs{}, &logs{}
+	// the default stream middlewares log by the logger of the options
+	srv := NewServer(Address("127.0.0.1:0"), Enable(0x03), Logger(svr))
+	go func() {
+		_ = srv.Start()
+	}()
+	defer srv.Stop(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := Dial(ctx, WithAddr(srv.listener.Addr().String()), WithEnable(0x03), WithStrategy(nil), WithLogger(cli))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	cx, stop := context.WithCancel(ctx)
+	stream, err := grpc_health_v1.NewHealthClient(conn).Watch(cx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+	for _, l := range []*logs{cli, svr} {
+		for i := 0; i < 100 && l.count("stream message log") < 2; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if l.count("request log") != 1 || l.count("stream message log") != 2 {
+			t.Fatalf("logs:%q", l.msgs)
+		}
+	}
+}
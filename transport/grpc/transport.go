@@ -1,6 +1,8 @@
 package grpc
 
 import (
+	"github.com/go-slark/slark/middleware"
+	utils "github.com/go-slark/slark/pkg"
 	"github.com/go-slark/slark/transport"
 	"github.com/go-slark/slark/transport/grpc/balancer/node"
 	"google.golang.org/grpc/metadata"
@@ -62,3 +64,14 @@ func (t *Transport) ReqCarrier() transport.Carrier {
 func (t *Transport) RspCarrier() transport.Carrier {
 	return t.rsp
 }
+
+// streamable keeps the enabled middlewares observing the streamed messages, nil is not streamable
+func streamable(mws []middleware.Middleware, enable int64) []middleware.Middleware {
+	v := make([]middleware.Middleware, 0, len(mws))
+	for _, mw := range utils.Filter(mws, enable) {
+		if mw != nil {
+			v = append(v, mw)
+		}
+	}
+	return v
+}
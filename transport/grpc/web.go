@@ -0,0 +1,544 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	stdjson "encoding/json"
+	"fmt"
+	"github.com/go-slark/slark/encoding"
+	"github.com/go-slark/slark/encoding/json"
+	"github.com/go-slark/slark/errors"
+	utils "github.com/go-slark/slark/pkg"
+	"github.com/go-slark/slark/transport/http/handler"
+	"golang.org/x/net/http2"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc/codes"
+	grpcencoding "google.golang.org/grpc/encoding"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	grpcWeb       = "application/grpc-web"
+	grpcWebText   = "application/grpc-web-text"
+	connect       = "application/connect+"
+	trailerFlag   = 0x80
+	endStreamFlag = 0x02
+	sniffTimeout  = 10 * time.Second
+)
+
+var registerJSON sync.Once
+
+var (
+	webAllowedHeaders = []string{"Origin", "Content-Length", "Content-Type", "Accept-Encoding", "Authorization", "X-CSRF-Token", utils.Authorization, utils.Token,
+		"X-Grpc-Web", "X-User-Agent", "Grpc-Timeout", "Connect-Protocol-Version", "Connect-Timeout-Ms"}
+	webExposedHeaders = []string{utils.Authorization, utils.Token, "Grpc-Status", "Grpc-Message", "Grpc-Status-Details-Bin"}
+)
+
+// Web serves gRPC-Web and Connect besides native gRPC on the listener of the server, the cors options are applied by handler.CORS,
+// the connections are split so that native gRPC is still served by grpc.Server.Serve, see serveHTTP,
+// the json codec of grpc is registered once Web is used
+func Web(opts ...handler.Option) ServerOption {
+	return func(s *Server) {
+		// application/grpc+json, so that the json of grpc-web and connect is served by the grpc server
+		registerJSON.Do(func() {
+			grpcencoding.RegisterCodec(encoding.GetCodec(json.Name))
+		})
+		s.web = true
+		s.cors = append([]handler.Option{handler.AllowedHeaders(webAllowedHeaders), handler.ExposedHeaders(webExposedHeaders)}, opts...)
+	}
+}
+
+// WebHandler serves the gRPC-Web and Connect requests of the registered methods, and the native gRPC requests over HTTP/2,
+// the others are served by next, so that it is installed on the http server by http.Handlers(handler.CORS(...), srv.WebHandler)
+func (s *Server) WebHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ct := strings.ToLower(r.Header.Get(utils.ContentType))
+		switch {
+		case r.Method != http.MethodPost:
+			next.ServeHTTP(w, r)
+		case strings.HasPrefix(ct, grpcWeb):
+			s.serveWeb(w, r, ct)
+		case r.ProtoMajor == 2 && strings.HasPrefix(ct, "application/grpc"):
+			s.Server.ServeHTTP(w, r)
+		case s.registered(r.URL.Path) && (strings.HasPrefix(ct, connect) || ct == "application/proto" || strings.HasPrefix(ct, "application/json")):
+			s.serveConnect(w, r, ct)
+		default:
+			next.ServeHTTP(w, r)
+		}
+	})
+}
+
+// registered reports whether the path is a method such as /package.Service/Method of the server
+func (s *Server) registered(path string) bool {
+	s.methodsOnce.Do(func() {
+		s.methods = make(map[string]struct{})
+		for name, info := range s.GetServiceInfo() {
+			for _, m := range info.Methods {
+				s.methods[fmt.Sprintf("/%s/%s", name, m.Name)] = struct{}{}
+			}
+		}
+	})
+	_, ok := s.methods[path]
+	return ok
+}
+
+// grpcRequest converts the request into a native gRPC request of the content subtype
+func grpcRequest(r *http.Request, subtype string, body io.Reader) *http.Request {
+	req := r.Clone(r.Context())
+	req.ProtoMajor, req.ProtoMinor, req.Proto = 2, 0, "HTTP/2"
+	req.Header.Set(utils.ContentType, "application/grpc+"+subtype)
+	req.Header.Del("Content-Length")
+	req.Header.Set("Te", "trailers")
+	req.ContentLength = -1
+	req.Body = io.NopCloser(body)
+	return req
+}
+
+func subtype(ct, prefix string) string {
+	ct = strings.TrimPrefix(ct, prefix)
+	ct = strings.TrimPrefix(ct, "+")
+	if i := strings.Index(ct, ";"); i >= 0 {
+		ct = ct[:i]
+	}
+	if len(ct) == 0 {
+		return "proto"
+	}
+	return ct
+}
+
+func (s *Server) serveWeb(w http.ResponseWriter, r *http.Request, ct string) {
+	text := strings.HasPrefix(ct, grpcWebText)
+	prefix := grpcWeb
+	var body io.Reader = r.Body
+	if text {
+		prefix = grpcWebText
+		body = base64.NewDecoder(base64.StdEncoding, r.Body)
+	}
+	_ = http.NewResponseController(w).EnableFullDuplex()
+	rw := newResponseWriter(w)
+	rw.contentType = ct
+	rw.text = text
+	s.Server.ServeHTTP(rw, grpcRequest(r, subtype(ct, prefix), body))
+	rw.finish(func(trailer http.Header) []byte {
+		var buf bytes.Buffer
+		for k, v := range trailer {
+			for _, value := range v {
+				buf.WriteString(strings.ToLower(k) + ": " + value + "\r\n")
+			}
+		}
+		return frame(trailerFlag, buf.Bytes())
+	})
+}
+
+func (s *Server) serveConnect(w http.ResponseWriter, r *http.Request, ct string) {
+	if ms := r.Header.Get("Connect-Timeout-Ms"); len(ms) > 0 {
+		r.Header.Set("Grpc-Timeout", ms+"m")
+	}
+	if strings.HasPrefix(ct, connect) {
+		// streaming, the envelopes of the request are the same as the frames of gRPC
+		_ = http.NewResponseController(w).EnableFullDuplex()
+		rw := newResponseWriter(w)
+		rw.contentType = ct
+		s.Server.ServeHTTP(rw, grpcRequest(r, subtype(ct, connect), r.Body))
+		rw.finish(func(trailer http.Header) []byte {
+			end := map[string]interface{}{}
+			if e := connectError(trailer); e != nil {
+				end["error"] = e
+			}
+			md := map[string][]string{}
+			for k, v := range trailer {
+				if !strings.HasPrefix(strings.ToLower(k), "grpc-") {
+					md[strings.ToLower(k)] = v
+				}
+			}
+			if len(md) > 0 {
+				end["metadata"] = md
+			}
+			data, _ := stdjson.Marshal(end)
+			return frame(endStreamFlag, data)
+		})
+		return
+	}
+
+	// unary, the message is the body of the request and the response
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeConnectError(w, &connectErr{Code: "invalid_argument", Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	st := subtype(strings.TrimPrefix(ct, "application/"), "")
+	rw := newResponseWriter(nil)
+	s.Server.ServeHTTP(rw, grpcRequest(r, st, bytes.NewReader(frame(0, data))))
+	trailer := rw.trailer()
+	for k, v := range rw.header {
+		if k == "Trailer" || strings.HasPrefix(strings.ToLower(k), "grpc-") || strings.HasPrefix(k, http2.TrailerPrefix) {
+			continue
+		}
+		w.Header()[k] = v
+	}
+	for k, v := range trailer {
+		if !strings.HasPrefix(strings.ToLower(k), "grpc-") {
+			w.Header()["Trailer-"+k] = v
+		}
+	}
+	if e := connectError(trailer); e != nil {
+		code, _ := strconv.Atoi(trailer.Get("Grpc-Status"))
+		writeConnectError(w, e, errors.GRPCToHTTPCode(codes.Code(code)))
+		return
+	}
+	msg, _, err := readFrame(bufio.NewReader(&rw.body))
+	if err != nil && err != io.EOF {
+		writeConnectError(w, &connectErr{Code: "internal", Message: err.Error()}, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(utils.ContentType, "application/"+st)
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(msg)
+}
+
+type connectErr struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// connectError returns the error of a non OK grpc-status trailer
+func connectError(trailer http.Header) *connectErr {
+	code, err := strconv.Atoi(trailer.Get("Grpc-Status"))
+	if err != nil {
+		return &connectErr{Code: "internal", Message: "missing grpc-status"}
+	}
+	if codes.Code(code) == codes.OK {
+		return nil
+	}
+	return &connectErr{
+		Code:    connectCode(codes.Code(code)),
+		Message: grpcMessage(trailer.Get("Grpc-Message")),
+	}
+}
+
+// connectCode converts a code such as InvalidArgument to invalid_argument
+func connectCode(code codes.Code) string {
+	if code == codes.Canceled {
+		return "canceled"
+	}
+	var b strings.Builder
+	for i, c := range code.String() {
+		if c >= 'A' && c <= 'Z' {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			c += 'a' - 'A'
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// grpcMessage decodes the percent encoded grpc-message
+func grpcMessage(msg string) string {
+	var b strings.Builder
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '%' && i+2 < len(msg) {
+			v, err := strconv.ParseUint(msg[i+1:i+3], 16, 8)
+			if err == nil {
+				b.WriteByte(byte(v))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(msg[i])
+	}
+	return b.String()
+}
+
+func writeConnectError(w http.ResponseWriter, e *connectErr, code int) {
+	data, _ := stdjson.Marshal(e)
+	w.Header().Set(utils.ContentType, "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+func frame(flag byte, data []byte) []byte {
+	buf := make([]byte, 5+len(data))
+	buf[0] = flag
+	binary.BigEndian.PutUint32(buf[1:5], uint32(len(data)))
+	copy(buf[5:], data)
+	return buf
+}
+
+func readFrame(r *bufio.Reader) ([]byte, byte, error) {
+	header := make([]byte, 5)
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, 0, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header[1:5]))
+	_, err = io.ReadFull(r, data)
+	return data, header[0], err
+}
+
+// responseWriter keeps the headers and trailers written by the grpc server,
+// the frames are written through to w if any, or kept in body
+type responseWriter struct {
+	w           http.ResponseWriter
+	header      http.Header
+	sent        map[string]struct{}
+	body        bytes.Buffer
+	contentType string
+	text        bool
+	wrote       bool
+	l           sync.Mutex
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{w: w, header: http.Header{}}
+}
+
+func (rw *responseWriter) Header() http.Header {
+	return rw.header
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if rw.wrote {
+		return
+	}
+	rw.wrote = true
+	rw.sent = make(map[string]struct{}, len(rw.header))
+	for k := range rw.header {
+		rw.sent[k] = struct{}{}
+	}
+	if rw.w == nil {
+		return
+	}
+	h := rw.w.Header()
+	for k, v := range rw.header {
+		if k == "Trailer" {
+			continue
+		}
+		h[k] = v
+	}
+	h.Set(utils.ContentType, rw.contentType)
+	h.Del("Content-Length")
+	rw.w.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(data []byte) (int, error) {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+	if !rw.wrote {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.w == nil || rw.text {
+		// the text is encoded by flush
+		return rw.body.Write(data)
+	}
+	return rw.w.Write(data)
+}
+
+func (rw *responseWriter) Flush() {
+	rw.l.Lock()
+	defer rw.l.Unlock()
+	if !rw.wrote {
+		rw.WriteHeader(http.StatusOK)
+	}
+	if rw.w == nil {
+		return
+	}
+	if rw.text && rw.body.Len() > 0 {
+		_, _ = rw.w.Write([]byte(base64.StdEncoding.EncodeToString(rw.body.Bytes())))
+		rw.body.Reset()
+	}
+	_ = http.NewResponseController(rw.w).Flush()
+}
+
+// trailer returns the headers set after the header was written, or all if it is a trailers only response
+func (rw *responseWriter) trailer() http.Header {
+	trailer := http.Header{}
+	for k, v := range rw.header {
+		if k == "Trailer" {
+			continue
+		}
+		if strings.HasPrefix(k, http2.TrailerPrefix) {
+			trailer[http.CanonicalHeaderKey(strings.TrimPrefix(k, http2.TrailerPrefix))] = v
+			continue
+		}
+		if _, ok := rw.sent[k]; ok {
+			continue
+		}
+		trailer[k] = v
+	}
+	return trailer
+}
+
+// finish writes the trailer as the last frame
+func (rw *responseWriter) finish(last func(http.Header) []byte) {
+	data := last(rw.trailer())
+	rw.l.Lock()
+	if !rw.wrote {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(data)
+	if rw.text {
+		_, _ = rw.w.Write([]byte(base64.StdEncoding.EncodeToString(rw.body.Bytes())))
+	} else {
+		_, _ = rw.w.Write(rw.body.Bytes())
+	}
+	rw.body.Reset()
+	rw.l.Unlock()
+	_ = http.NewResponseController(rw.w).Flush()
+}
+
+// serveHTTP serves native gRPC by grpc.Server.Serve, and gRPC-Web and Connect by the http server on the listener,
+// the connections are split by the h2c preface in plaintext, or by the client hello offering h2 only in tls,
+// so that the browsers and the clients offering http/1.1 are served by the http server
+func (s *Server) serveHTTP() error {
+	native, web := newConnListener(s.listener.Addr()), newConnListener(s.listener.Addr())
+	eg := errgroup.Group{}
+	eg.Go(func() error {
+		return s.Serve(native)
+	})
+	eg.Go(func() error {
+		var err error
+		if s.tls != nil {
+			err = s.webSrv.ServeTLS(web, "", "")
+		} else {
+			err = s.webSrv.Serve(web)
+		}
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	})
+	eg.Go(func() error {
+		for {
+			conn, err := s.listener.Accept()
+			if err != nil {
+				native.Close()
+				web.Close()
+				if errors.Is(err, net.ErrClosed) {
+					return nil
+				}
+				return err
+			}
+			go s.split(conn, native, web)
+		}
+	})
+	return eg.Wait()
+}
+
+func (s *Server) split(conn net.Conn, native, web *connListener) {
+	_ = conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	ok, sniffed, err := sniff(conn, s.tls != nil)
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	c := &sniffConn{Conn: conn, r: io.MultiReader(sniffed, conn)}
+	if ok {
+		native.deliver(c)
+	} else {
+		web.deliver(c)
+	}
+}
+
+// sniff reports whether the connection is native gRPC, and returns the bytes read
+func sniff(conn net.Conn, tlsConn bool) (bool, io.Reader, error) {
+	if tlsConn {
+		buf := &bytes.Buffer{}
+		var protos []string
+		_ = tls.Server(&helloConn{Conn: conn, r: io.TeeReader(conn, buf)}, &tls.Config{
+			GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				protos = hello.SupportedProtos
+				return nil, fmt.Errorf("client hello sniffed")
+			},
+		}).Handshake()
+		if buf.Len() == 0 {
+			return false, nil, io.ErrUnexpectedEOF
+		}
+		return len(protos) == 1 && protos[0] == http2.NextProtoTLS, buf, nil
+	}
+	preface := []byte(http2.ClientPreface)
+	buf := make([]byte, 0, len(preface))
+	for len(buf) < len(preface) && bytes.HasPrefix(preface, buf) {
+		n, err := conn.Read(buf[len(buf):cap(buf)])
+		buf = buf[:len(buf)+n]
+		if err != nil {
+			return false, nil, err
+		}
+	}
+	return bytes.Equal(buf, preface), bytes.NewReader(buf), nil
+}
+
+// helloConn reads the client hello and discards the alert of the aborted handshake
+type helloConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *helloConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func (c *helloConn) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+// sniffConn replays the sniffed bytes before reading the connection
+type sniffConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *sniffConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+// connListener is the net.Listener of the connections split from the listener of the server
+type connListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newConnListener(addr net.Addr) *connListener {
+	return &connListener{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *connListener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		_ = conn.Close()
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,214 @@
+package grpc
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const healthCheck = "/grpc.health.v1.Health/Check"
+
+func serveWeb(t *testing.T, srv *Server, ct string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, healthCheck, bytes.NewReader(body))
+	req.Header.Set("Content-Type", ct)
+	rec := httptest.NewRecorder()
+	srv.WebHandler(http.NotFoundHandler()).ServeHTTP(rec, req)
+	return rec
+}
+
+// decodeText decodes the padded base64 chunks of grpc-web-text
+func decodeText(t *testing.T, text string) []byte {
+	var data []byte
+	for len(text) > 0 {
+		n := 4
+		for n < len(text) && !strings.Contains(text[n-4:n], "=") {
+			n += 4
+		}
+		chunk, err := base64.StdEncoding.DecodeString(text[:n])
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(data, chunk...)
+		text = text[n:]
+	}
+	return data
+}
+
+func TestGRPCWeb(t *testing.T) {
+	srv := NewServer(Address("127.0.0.1:0"))
+	in, err := proto.Marshal(&grpc_health_v1.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ct := range []string{grpcWeb + "+proto", grpcWebText} {
+		body := frame(0, in)
+		if ct == grpcWebText {
+			body = []byte(base64.StdEncoding.EncodeToString(body))
+		}
+		rec := serveWeb(t, srv, ct, body)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != ct {
+			t.Fatalf("%s, code:%d, header:%v", ct, rec.Code, rec.Header())
+		}
+		data := rec.Body.Bytes()
+		if ct == grpcWebText {
+			data = decodeText(t, rec.Body.String())
+		}
+		r := bufio.NewReader(bytes.NewReader(data))
+		msg, flag, err := readFrame(r)
+		if err != nil || flag != 0 {
+			t.Fatalf("%s, message frame flag:%d, err:%v", ct, flag, err)
+		}
+		out := &grpc_health_v1.HealthCheckResponse{}
+		if err = proto.Unmarshal(msg, out); err != nil || out.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+			t.Fatalf("%s, status:%v, err:%v", ct, out.Status, err)
+		}
+		trailer, flag, err := readFrame(r)
+		if err != nil || flag != trailerFlag || !strings.Contains(string(trailer), "grpc-status: 0\r\n") {
+			t.Fatalf("%s, trailer frame flag:%d, trailer:%q, err:%v", ct, flag, trailer, err)
+		}
+	}
+}
+
+func TestConnect(t *testing.T) {
+	srv := NewServer(Address("127.0.0.1:0"), Web())
+	rec := serveWeb(t, srv, "application/json", []byte(`{}`))
+	var buf bytes.Buffer
+	_ = json.Compact(&buf, rec.Body.Bytes())
+	if rec.Code != http.StatusOK || buf.String() != `{"status":"SERVING"}` {
+		t.Fatalf("code:%d, body:%s", rec.Code, rec.Body.String())
+	}
+
+	rec = serveWeb(t, srv, "application/json", []byte(`{"service":"unknown"}`))
+	e := &connectErr{}
+	if err := json.Unmarshal(rec.Body.Bytes(), e); err != nil || rec.Code != http.StatusNotFound || e.Code != "not_found" {
+		t.Fatalf("code:%d, body:%s", rec.Code, rec.Body.String())
+	}
+
+	in, _ := proto.Marshal(&grpc_health_v1.HealthCheckRequest{})
+	rec = serveWeb(t, srv, connect+"proto", frame(0, in))
+	r := bufio.NewReader(rec.Body)
+	msg, flag, err := readFrame(r)
+	out := &grpc_health_v1.HealthCheckResponse{}
+	if err != nil || flag != 0 || proto.Unmarshal(msg, out) != nil || out.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("message frame flag:%d, err:%v", flag, err)
+	}
+	end, flag, err := readFrame(r)
+	if err != nil || flag != endStreamFlag || string(end) != "{}" {
+		t.Fatalf("end stream frame flag:%d, end:%s, err:%v", flag, end, err)
+	}
+}
+
+func TestWebNotRegistered(t *testing.T) {
+	srv := NewServer(Address("127.0.0.1:0"))
+	req := httptest.NewRequest(http.MethodPost, "/unknown.Service/Method", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.WebHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})).ServeHTTP(rec, req)
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("code:%d", rec.Code)
+	}
+}
+
+// connStats counts the connections begun, grpc.Server.Serve begins one per connection, grpc.Server.ServeHTTP one per request
+type connStats struct {
+	conns int32
+}
+
+func (s *connStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (s *connStats) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (s *connStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (s *connStats) HandleConn(_ context.Context, cs stats.ConnStats) {
+	if _, ok := cs.(*stats.ConnBegin); ok {
+		atomic.AddInt32(&s.conns, 1)
+	}
+}
+
+func TestWebServe(t *testing.T) {
+	// the certificate of 127.0.0.1
+	ts := httptest.NewUnstartedServer(nil)
+	ts.StartTLS()
+	cert := ts.TLS.Certificates
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	ts.Close()
+
+	for _, secure := range []bool{false, true} {
+		cs := &connStats{}
+		opts := []ServerOption{Address("127.0.0.1:0"), Web(), ServerOptions([]grpc.ServerOption{grpc.StatsHandler(cs)})}
+		creds, scheme := insecure.NewCredentials(), "http"
+		if secure {
+			opts = append(opts, TLS(&tls.Config{Certificates: cert}))
+			creds, scheme = credentials.NewTLS(&tls.Config{RootCAs: pool}), "https"
+		}
+		srv := NewServer(opts...)
+		go func() {
+			_ = srv.Start()
+		}()
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		if err := srv.Ready(ctx); err != nil {
+			t.Fatal(err)
+		}
+		addr := srv.listener.Addr().String()
+
+		// native grpc is served by grpc.Server.Serve
+		conn, err := grpc.DialContext(ctx, addr, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			rsp, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+			if err != nil || rsp.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+				t.Fatalf("tls:%v, rsp:%+v, error:%+v", secure, rsp, err)
+			}
+		}
+		if n := atomic.LoadInt32(&cs.conns); n != 1 {
+			t.Fatalf("tls:%v, conns:%d", secure, n)
+		}
+		_ = conn.Close()
+
+		// grpc-web over http/1.1 and h2 is served by the http server
+		in, _ := proto.Marshal(&grpc_health_v1.HealthCheckRequest{})
+		for _, h2 := range []bool{false, secure} {
+			client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: h2}}
+			hrsp, err := client.Post(scheme+"://"+addr+healthCheck, grpcWeb+"+proto", bytes.NewReader(frame(0, in)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, flag, err := readFrame(bufio.NewReader(hrsp.Body))
+			_ = hrsp.Body.Close()
+			out := &grpc_health_v1.HealthCheckResponse{}
+			if err != nil || flag != 0 || proto.Unmarshal(msg, out) != nil || out.Status != grpc_health_v1.HealthCheckResponse_SERVING || hrsp.ProtoMajor != map[bool]int{false: 1, true: 2}[h2] {
+				t.Fatalf("tls:%v, h2:%v, proto:%s, flag:%d, error:%+v", secure, h2, hrsp.Proto, flag, err)
+			}
+		}
+		if err := srv.Stop(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+	}
+}
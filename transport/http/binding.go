@@ -0,0 +1,55 @@
+package http
+
+import (
+	"github.com/go-slark/slark/encoding/form"
+	"google.golang.org/protobuf/proto"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var pathVar = regexp.MustCompile(`{([a-zA-Z0-9_.\s]+)(=[^{}]*)?}`)
+
+// EncodeURL replaces the {field} and {field=pattern} templates of path with the message fields,
+// the remaining fields except excludes are encoded into the query if needQuery
+func EncodeURL(path string, msg proto.Message, needQuery bool, excludes ...string) (string, error) {
+	var (
+		err  error
+		vars []string
+	)
+	path = pathVar.ReplaceAllStringFunc(path, func(s string) string {
+		name := strings.TrimSpace(pathVar.FindStringSubmatch(s)[1])
+		vars = append(vars, name)
+		value, e := form.EncodeField(msg, name)
+		if e != nil {
+			err = e
+			return s
+		}
+		// {name=users/*} keeps the slash of the value
+		if strings.Contains(s, "=") {
+			return value
+		}
+		return url.PathEscape(value)
+	})
+	if err != nil {
+		return "", err
+	}
+	if !needQuery {
+		return path, nil
+	}
+	values, err := form.EncodeValues(msg)
+	if err != nil {
+		return "", err
+	}
+	for _, key := range append(vars, excludes...) {
+		for k := range values {
+			if k == key || strings.HasPrefix(k, key+".") {
+				delete(values, k)
+			}
+		}
+	}
+	if len(values) == 0 {
+		return path, nil
+	}
+	return path + "?" + values.Encode(), nil
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"context"
+	"github.com/go-slark/slark/errors"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEncodeURL(t *testing.T) {
+	msg := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String("a b"),
+		Number:   proto.Int32(3),
+		JsonName: proto.String("x"),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+		Options:  &descriptorpb.FieldOptions{Packed: proto.Bool(true)},
+	}
+	path, err := EncodeURL("/v1/{name}/fields/{number}", msg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/a%20b/fields/3?json_name=x&label=LABEL_REPEATED&options.packed=true" {
+		t.Fatalf("path:%s", path)
+	}
+	path, err = EncodeURL("/v1/{json_name=users/*}", msg, true, "options", "label")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "/v1/x?name=a+b&number=3" {
+		t.Fatalf("path:%s", path)
+	}
+	_, err = EncodeURL("/v1/{unknown}", msg, false)
+	if err == nil {
+		t.Fatal("unknown field expected error")
+	}
+}
+
+func TestResponseDecoder(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	err := ResponseEncoder(req, rec, &descriptorpb.FieldDescriptorProto{Name: proto.String("ok")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &descriptorpb.FieldDescriptorProto{}
+	err = ResponseDecoder(context.Background(), rec.Result(), out)
+	if err != nil || out.GetName() != "ok" {
+		t.Fatalf("out:%+v, error:%+v", out, err)
+	}
+
+	rec = httptest.NewRecorder()
+	ErrorEncoder(req, rec, errors.NotFound("user not found", "USER_NOT_FOUND"))
+	err = ErrorDecoder(context.Background(), rec.Result())
+	e := errors.FromError(err)
+	if e.Code != http.StatusNotFound || e.Reason != "USER_NOT_FOUND" || e.Message != "user not found" {
+		t.Fatalf("error:%+v", err)
+	}
+	errors.RegisterMessages("zh-CN", map[string]string{"USER_NOT_FOUND": "用户不存在"})
+	rec = httptest.NewRecorder()
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9")
+	ErrorEncoder(req, rec, errors.NotFound("user not found", "USER_NOT_FOUND"))
+	if e = errors.FromError(ErrorDecoder(context.Background(), rec.Result())); e.Message != "用户不存在" {
+		t.Fatalf("error:%+v", e)
+	}
+}
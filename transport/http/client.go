@@ -4,11 +4,29 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"fmt"
 	"github.com/go-slark/slark/encoding"
 	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/logger"
+	"github.com/go-slark/slark/middleware"
+	"github.com/go-slark/slark/middleware/flexible/breaker"
+	"github.com/go-slark/slark/middleware/logging"
+	"github.com/go-slark/slark/middleware/metadata"
+	"github.com/go-slark/slark/middleware/metrics"
+	"github.com/go-slark/slark/middleware/recovery"
+	"github.com/go-slark/slark/middleware/tracing"
 	utils "github.com/go-slark/slark/pkg"
+	"github.com/go-slark/slark/pkg/endpoint"
+	"github.com/go-slark/slark/pkg/opentelemetry/metric"
+	"github.com/go-slark/slark/pkg/retry"
+	"github.com/go-slark/slark/registry"
+	"github.com/go-slark/slark/transport"
+	"github.com/go-slark/slark/transport/grpc/balancer/algo"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"net/http"
+	"net/url"
 	"time"
 )
 
@@ -16,20 +34,57 @@ type Client struct {
 	*http.Client
 	transport http.RoundTripper
 	tls       *tls.Config
+	discovery registry.Discovery
+	target    string
+	endpoint  *url.URL
+	builder   node.Builder
+	filters   []node.Filter
+	tm        time.Duration
+	resolver  *resolver
+	logger    logger.Logger
+	mws       []middleware.Middleware
+	enable    int64
+	retry     int
+	retryOpts []retry.Opt
+	methods   map[string]struct{} // the methods retried
+	envelope  Envelope
+	err       error
 }
 
 type ClientOption func(client *Client)
 
+// NewClient creates the client, the error of resolving the discovery target is returned by DoHTTPReq
 func NewClient(opts ...ClientOption) *Client {
 	client := &Client{
 		Client: &http.Client{
 			Timeout: 3 * time.Second,
 		},
 		transport: http.DefaultTransport,
+		builder:   algo.NewWRRBuilder(),
+		tm:        10 * time.Second,
+		logger:    logger.GetLogger(),
+		enable:    0x03, // low -> high
+		retry:     1,
+		methods: map[string]struct{}{
+			http.MethodGet:     {},
+			http.MethodHead:    {},
+			http.MethodPut:     {},
+			http.MethodDelete:  {},
+			http.MethodOptions: {},
+		},
+	}
+	client.mws = []middleware.Middleware{
+		tracing.Trace(trace.SpanKindClient),
+		logging.Log(middleware.Client, client.logger),
+		metrics.Metrics(middleware.Client, metric.WithCounter(metric.RequestCodeCounter())),
+		breaker.Breaker(),
+		recovery.Recovery(client.logger),
+		metadata.Metadata(middleware.Client), // opt-in by the bit 0x20 of WithEnable
 	}
 	for _, opt := range opts {
 		opt(client)
 	}
+	client.mws = utils.Filter(client.mws, client.enable)
 	if client.tls != nil {
 		transport, ok := client.transport.(*http.Transport)
 		if ok {
@@ -37,9 +92,20 @@ func NewClient(opts ...ClientOption) *Client {
 		}
 	}
 	client.Client.Transport = client.transport
+	if client.discovery != nil && len(client.target) > 0 {
+		client.resolver, client.err = newResolver(client.discovery, client.target, client.builder, endpoint.Scheme("http", client.tls == nil), client.tm)
+	}
 	return client
 }
 
+// Close stops watching the discovery target
+func (c *Client) Close() error {
+	if c.resolver != nil {
+		c.resolver.Close()
+	}
+	return nil
+}
+
 func Timeout(tm time.Duration) ClientOption {
 	return func(client *Client) {
 		client.Timeout = tm
@@ -58,6 +124,88 @@ func WithTLS(tls *tls.Config) ClientOption {
 	}
 }
 
+func WithDiscovery(discovery registry.Discovery) ClientOption {
+	return func(client *Client) {
+		client.discovery = discovery
+	}
+}
+
+// WithEndpoint :base url of the relative request url without discovery, such as http://127.0.0.1:8080
+func WithEndpoint(endpoint string) ClientOption {
+	return func(client *Client) {
+		client.endpoint, _ = url.Parse(endpoint)
+	}
+}
+
+// WithTarget :discovery:///service, request url path is relative to the picked node
+func WithTarget(target string) ClientOption {
+	return func(client *Client) {
+		client.target = target
+	}
+}
+
+func WithBalancer(builder node.Builder) ClientOption {
+	return func(client *Client) {
+		client.builder = builder
+	}
+}
+
+func WithFilters(filters []node.Filter) ClientOption {
+	return func(client *Client) {
+		client.filters = filters
+	}
+}
+
+// WithDiscoveryTimeout :timeout waiting for the initial nodes
+func WithDiscoveryTimeout(tm time.Duration) ClientOption {
+	return func(client *Client) {
+		client.tm = tm
+	}
+}
+
+func WithLogger(l logger.Logger) ClientOption {
+	return func(client *Client) {
+		client.logger = l
+	}
+}
+
+func WithMiddleware(mws ...middleware.Middleware) ClientOption {
+	return func(client *Client) {
+		client.mws = mws
+	}
+}
+
+func WithEnable(enable int64) ClientOption {
+	return func(client *Client) {
+		client.enable = enable
+	}
+}
+
+// WithRetry :max attempts of a request, only transport errors and 5xx responses of the idempotent methods are retried
+func WithRetry(attempts int, opts ...retry.Opt) ClientOption {
+	return func(client *Client) {
+		client.retry = attempts
+		client.retryOpts = opts
+	}
+}
+
+// WithRetryMethods :methods retried instead of GET, HEAD, PUT, DELETE, OPTIONS, such as the non idempotent POST
+func WithRetryMethods(methods ...string) ClientOption {
+	return func(client *Client) {
+		client.methods = make(map[string]struct{}, len(methods))
+		for _, method := range methods {
+			client.methods[method] = struct{}{}
+		}
+	}
+}
+
+// WithEnvelope :envelope of the responses without the X-Envelope header, ResponseDecoder unwraps the data of StatusEnvelope
+func WithEnvelope(e Envelope) ClientOption {
+	return func(client *Client) {
+		client.envelope = e
+	}
+}
+
 type Encoder func(ctx context.Context, typ string, v interface{}) ([]byte, error)
 
 type Decoder func(ctx context.Context, rsp *http.Response, v interface{}) error
@@ -139,34 +287,121 @@ func (r *Request) ErrDecoder(errDec ErrDecoder) *Request {
 }
 
 func (c *Client) DoHTTPReq(ctx context.Context, req *Request, v interface{}) error {
-	var body io.Reader
+	if c.err != nil {
+		return c.err
+	}
+	var body []byte
 	if req.param != nil {
 		enc, err := req.encoder(ctx, req.header["Content-Type"], req.param)
 		if err != nil {
 			return err
 		}
-		body = bytes.NewReader(enc)
+		body = enc
 	}
 
-	request, err := http.NewRequest(req.method, req.url, body)
+	u, err := url.Parse(req.url)
 	if err != nil {
 		return err
 	}
-
+	header := http.Header{}
 	for hk, hv := range req.header {
-		request.Header.Set(hk, hv)
+		header.Set(hk, hv)
+	}
+	trans := &Transport{
+		Operation: fmt.Sprintf("%s %s", req.method, u.Path),
+		Req:       Carrier(header),
+		Rsp:       Carrier{},
+		filters:   c.filters,
 	}
+	ctx = transport.NewClientContext(ctx, trans)
+	if c.envelope != nil {
+		ctx = NewEnvelopeContext(ctx, c.envelope)
+	}
+	_, err = middleware.ComposeMiddleware(c.mws...)(func(ctx context.Context, _ interface{}) (interface{}, error) {
+		rsp, err := c.invoke(ctx, req, u, body, trans)
+		if err != nil {
+			return nil, err
+		}
+		defer rsp.Body.Close()
+		return v, req.decoder(ctx, rsp, v)
+	})(ctx, req.param)
+	return err
+}
 
-	rsp, err := c.Do(request)
+// invoke sends the request, retrying on transport errors and 5xx responses of the methods retried
+func (c *Client) invoke(ctx context.Context, req *Request, u *url.URL, body []byte, trans *Transport) (*http.Response, error) {
+	_, ok := c.methods[req.method]
+	if c.retry <= 1 || !ok {
+		return c.do(ctx, req, u, body, trans)
+	}
+	var (
+		rsp *http.Response
+		err error
+	)
+	opts := append([]retry.Opt{retry.Retry(c.retry), retry.Timer(timer(ctx))}, c.retryOpts...)
+	_ = retry.NewOption(opts...).Retry(func() error {
+		rsp, err = c.do(ctx, req, u, body, trans)
+		if err != nil && retryable(ctx, err) {
+			return err
+		}
+		return nil
+	})
+	return rsp, err
+}
+
+func (c *Client) do(ctx context.Context, req *Request, u *url.URL, body []byte, trans *Transport) (*http.Response, error) {
+	target := *u
+	if c.resolver != nil && len(target.Host) == 0 {
+		err := c.resolver.pick(ctx, &target, trans.Filter()...)
+		if err != nil {
+			return nil, err
+		}
+	} else if c.endpoint != nil && len(target.Host) == 0 {
+		target.Scheme = c.endpoint.Scheme
+		target.Host = c.endpoint.Host
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, req.method, target.String(), reader)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer rsp.Body.Close()
+	request.Header = http.Header(trans.Req).Clone()
 
+	rsp, err := c.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	trans.Rsp = Carrier(rsp.Header)
 	err = req.errDecoder(ctx, rsp)
 	if err != nil {
-		return err
+		_ = rsp.Body.Close()
+		return nil, err
+	}
+	return rsp, nil
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
 	}
+	return errors.FromError(err).Code >= http.StatusInternalServerError
+}
 
-	return req.decoder(ctx, rsp, v)
+// timer stops waiting between attempts once ctx is done
+func timer(ctx context.Context) func(time.Duration) <-chan time.Time {
+	return func(d time.Duration) <-chan time.Time {
+		c := make(chan time.Time, 1)
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case tm := <-t.C:
+			c <- tm
+		case <-ctx.Done():
+			c <- time.Now()
+		}
+		return c
+	}
 }
@@ -0,0 +1,156 @@
+package http
+
+import (
+	"context"
+	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/middleware"
+	"github.com/go-slark/slark/pkg/retry"
+	"github.com/go-slark/slark/registry"
+	"github.com/go-slark/slark/registry/memory"
+	"github.com/go-slark/slark/transport"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientDiscovery(t *testing.T) {
+	ctx := context.Background()
+	hits := make(chan string, 16)
+	newServer := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits <- name
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"name":"` + name + `"}`))
+		}))
+	}
+	s1, s2 := newServer("s1"), newServer("s2")
+	defer s1.Close()
+	defer s2.Close()
+
+	r := memory.NewRegistry()
+	svc1 := &registry.Service{ID: "1", Name: "test", Endpoint: []string{"grpc://127.0.0.1:9090", s1.URL}}
+	svc2 := &registry.Service{ID: "2", Name: "test", Endpoint: []string{s2.URL}}
+	_ = r.Register(ctx, svc1)
+	_ = r.Register(ctx, svc2)
+
+	c := NewClient(WithDiscovery(r), WithTarget("discovery:///test"))
+	defer c.Close()
+
+	seen := map[string]bool{}
+	for i := 0; i < 4; i++ {
+		rsp := map[string]string{}
+		err := c.DoHTTPReq(ctx, NewRequest().Method(http.MethodGet).URL("/ping"), &rsp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen[<-hits] = true
+	}
+	if !seen["s1"] || !seen["s2"] {
+		t.Fatalf("nodes not balanced:%+v", seen)
+	}
+
+	_ = r.Unregister(ctx, svc1)
+	deadline := time.Now().Add(3 * time.Second)
+	for n := 0; n < 4; {
+		if time.Now().After(deadline) {
+			t.Fatal("node set not refreshed")
+		}
+		rsp := map[string]string{}
+		err := c.DoHTTPReq(ctx, NewRequest().Method(http.MethodGet).URL("/ping"), &rsp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if <-hits == "s1" {
+			n = 0
+			continue
+		}
+		n++
+	}
+
+	// the deregistered nodes are not kept when none is left
+	_ = r.Unregister(ctx, svc2)
+	deadline = time.Now().Add(3 * time.Second)
+	for {
+		if time.Now().After(deadline) {
+			t.Fatal("deregistered nodes kept")
+		}
+		rsp := map[string]string{}
+		err := c.DoHTTPReq(ctx, NewRequest().Method(http.MethodGet).URL("/ping"), &rsp)
+		if errors.Reason(err) == "NO_AVAILABLE_NODE" {
+			break
+		}
+		if err == nil {
+			<-hits
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err := NewClient(WithDiscovery(r), WithTarget("http://test")).DoHTTPReq(ctx, NewRequest().Method(http.MethodGet).URL("/ping"), nil)
+	if err == nil {
+		t.Fatal("invalid target scheme expected error")
+	}
+}
+
+func TestClientMiddlewareRetry(t *testing.T) {
+	var attempts, total int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&total, 1)
+		if r.Header.Get("X-Test") != "mw" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"name":"ok"}`))
+	}))
+	defer srv.Close()
+
+	var operation string
+	mw := func(handler middleware.Handler) middleware.Handler {
+		return func(ctx context.Context, req interface{}) (interface{}, error) {
+			trans, ok := transport.FromClientContext(ctx)
+			if !ok {
+				t.Fatal("client transport not found")
+			}
+			operation = trans.Operate()
+			trans.ReqCarrier().Set("X-Test", "mw")
+			return handler(ctx, req)
+		}
+	}
+	c := NewClient(WithMiddleware(mw), WithEnable(0x01), WithRetry(3, retry.Delay(time.Millisecond)))
+	rsp := map[string]string{}
+	err := c.DoHTTPReq(context.Background(), NewRequest().Method(http.MethodGet).URL(srv.URL+"/ping"), &rsp)
+	if err != nil || rsp["name"] != "ok" || atomic.LoadInt32(&attempts) != 3 {
+		t.Fatalf("rsp:%+v, attempts:%d, error:%+v", rsp, attempts, err)
+	}
+	if operation != "GET /ping" {
+		t.Fatalf("operation:%s", operation)
+	}
+
+	// 4xx is not retried
+	atomic.StoreInt32(&total, 0)
+	c = NewClient(WithEnable(0), WithRetry(3, retry.Delay(time.Millisecond)))
+	err = c.DoHTTPReq(context.Background(), NewRequest().Method(http.MethodGet).URL(srv.URL+"/ping"), &rsp)
+	if !errors.IsBadRequest(err) || atomic.LoadInt32(&total) != 1 {
+		t.Fatalf("total:%d, error:%+v", total, err)
+	}
+
+	// the non idempotent methods are retried only if opted in
+	post := func(c *Client) int32 {
+		atomic.StoreInt32(&attempts, 0)
+		atomic.StoreInt32(&total, 0)
+		_ = c.DoHTTPReq(context.Background(), NewRequest().Method(http.MethodPost).URL(srv.URL+"/ping").Header(map[string]string{"X-Test": "mw"}), &rsp)
+		return atomic.LoadInt32(&total)
+	}
+	if n := post(NewClient(WithEnable(0), WithRetry(3, retry.Delay(time.Millisecond)))); n != 1 {
+		t.Fatalf("post retried:%d", n)
+	}
+	if n := post(NewClient(WithEnable(0), WithRetry(3, retry.Delay(time.Millisecond)), WithRetryMethods(http.MethodPost))); n != 3 {
+		t.Fatalf("post attempts:%d", n)
+	}
+}
@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"fmt"
 	"github.com/go-slark/slark/encoding"
 	"github.com/go-slark/slark/encoding/form"
@@ -97,55 +98,121 @@ func SetContentType(subtype string) string {
 	return strings.Join([]string{utils.Application, subtype}, "/")
 }
 
-type Header struct {
-	Code int    `json:"code"`
-	Msg  string `json:"msg"`
-}
-
-type Response struct {
-	*Header
-	Data interface{} `json:"data"`
-}
-
 func ResponseEncoder(req *http.Request, rsp http.ResponseWriter, v interface{}) error {
-	r := &Response{
-		Header: &Header{
-			Msg: "成功",
-		},
-		Data: v,
-	}
-
 	codec, _ := Codec(req, utils.Accept)
-	hb, err := codec.Marshal(r.Header)
+	envelope := EnvelopeFromContext(req.Context())
+	codec, data, err := marshal(codec, envelope.Reply(v), v)
 	if err != nil {
 		return err
 	}
-	pb, err := codec.Marshal(r.Data)
-	if err != nil {
-		return err
-	}
-	data := make([]byte, 0, len(hb)+len(pb)+8)
-	data = append(data, hb[:len(hb)-1]...)
-	data = append(data, []byte(`,"data":`)...)
-	data = append(data, pb...)
-	data = append(data, '}')
 	rsp.Header().Set(utils.ContentType, SetContentType(codec.Name()))
-	rsp.WriteHeader(0)
+	rsp.Header().Set(utils.XEnvelope, envelope.Name())
+	rsp.WriteHeader(http.StatusOK)
 	_, err = rsp.Write(data)
 	return err
 }
 
 func ErrorEncoder(req *http.Request, rsp http.ResponseWriter, err error) {
-	e := errors.FromError(err)
-	response := &Response{
-		Header: &Header{
-			Code: int(e.Code),
-			Msg:  e.Message,
-		},
-	}
+	e := errors.FromError(err).Localize(req.Header.Get(utils.AcceptLanguage))
+	envelope := EnvelopeFromContext(req.Context())
 	codec, _ := Codec(req, utils.Accept)
-	data, _ := codec.Marshal(response)
-	rsp.Header().Set(utils.ContentType, SetContentType(codec.Name()))
-	rsp.WriteHeader(int(e.Code))
+	codec, data, err := marshal(codec, envelope.Error(e), &e.Status)
+	if err != nil {
+		codec = encoding.GetCodec(json.Name)
+		data, _ = codec.Marshal(envelope.Error(e))
+	}
+	rsp.Header().Set(utils.ContentType, envelope.ErrorContentType(codec.Name()))
+	rsp.WriteHeader(statusCode(int(e.Code)))
 	_, _ = rsp.Write(data)
 }
+
+// responseCodec returns the codec of the response content type, application/problem+json uses the json codec
+func responseCodec(rsp *http.Response) encoding.Codec {
+	name := SubContentType(rsp.Header.Get(utils.ContentType))
+	if i := strings.LastIndex(name, "+"); i >= 0 {
+		name = name[i+1:]
+	}
+	codec := encoding.GetCodec(name)
+	if codec == nil {
+		codec = encoding.GetCodec(json.Name)
+	}
+	return codec
+}
+
+// ResponseDecoder decodes the data of the response written by ResponseEncoder into v,
+// the status envelope is unwrapped by the X-Envelope header of the response or else the envelope of the client
+func ResponseDecoder(ctx context.Context, rsp *http.Response, v interface{}) error {
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return err
+	}
+	if len(body) == 0 {
+		return nil
+	}
+	name := rsp.Header.Get(utils.XEnvelope)
+	if len(name) == 0 {
+		e, ok := envelopeFromContext(ctx)
+		if ok {
+			name = e.Name()
+		}
+	}
+	codec := responseCodec(rsp)
+	if name == StatusEnvelope.Name() {
+		return unwrap(codec, body, v)
+	}
+	return codec.Unmarshal(body, v)
+}
+
+// errorBody is the union of the error bodies written by the envelopes
+type errorBody struct {
+	Code     int               `json:"code" xml:"code" msgpack:"code"`
+	Msg      string            `json:"msg" xml:"msg" msgpack:"msg"`
+	Message  string            `json:"message" xml:"message" msgpack:"message"`
+	Reason   string            `json:"reason" xml:"reason" msgpack:"reason"`
+	Status   int               `json:"status" xml:"status" msgpack:"status"`
+	Detail   string            `json:"detail" xml:"detail" msgpack:"detail"`
+	Metadata map[string]string `json:"metadata" xml:"-" msgpack:"metadata"`
+}
+
+// ErrorDecoder decodes the non 2xx response written by ErrorEncoder into *errors.Error
+func ErrorDecoder(_ context.Context, rsp *http.Response) error {
+	if rsp.StatusCode >= http.StatusOK && rsp.StatusCode < http.StatusMultipleChoices {
+		return nil
+	}
+	body, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return errors.New(rsp.StatusCode, err.Error(), errors.UnknownReason).WithError(err)
+	}
+	codec := responseCodec(rsp)
+	r := &errorBody{}
+	if codec.Name() == "proto" {
+		status := &errors.Status{}
+		err = codec.Unmarshal(body, status)
+		r.Code, r.Message, r.Reason, r.Metadata = int(status.Code), status.Message, status.Reason, status.Metadata
+	} else {
+		err = codec.Unmarshal(body, r)
+	}
+	if len(r.Msg) == 0 {
+		r.Msg = r.Message
+	}
+	if len(r.Msg) == 0 {
+		r.Msg = r.Detail
+	}
+	if err != nil || (r.Code == 0 && r.Status == 0 && len(r.Msg) == 0) {
+		return errors.New(rsp.StatusCode, string(body), errors.UnknownReason)
+	}
+	if r.Code == 0 {
+		r.Code = r.Status
+	}
+	if r.Code == 0 {
+		r.Code = rsp.StatusCode
+	}
+	if len(r.Reason) == 0 {
+		r.Reason = errors.UnknownReason
+	}
+	e := errors.New(r.Code, r.Msg, r.Reason)
+	if len(r.Metadata) > 0 {
+		e.Metadata = r.Metadata
+	}
+	return e
+}
@@ -0,0 +1,236 @@
+package http
+
+import (
+	"context"
+	stdjson "encoding/json"
+	"encoding/xml"
+	"github.com/go-slark/slark/encoding"
+	"github.com/go-slark/slark/encoding/json"
+	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/transport/http/handler"
+	"google.golang.org/protobuf/proto"
+	"net/http"
+)
+
+// Envelope wraps the replies written by ResponseEncoder and the errors written by ErrorEncoder
+type Envelope interface {
+	// Name returns the name written in the X-Envelope header of the replies, ResponseDecoder unwraps the data by it
+	Name() string
+	// Reply returns the body of a reply
+	Reply(v interface{}) interface{}
+	// Error returns the body of an error
+	Error(e *errors.Error) interface{}
+	// ErrorContentType returns the content type of an error body encoded by the codec
+	ErrorContentType(codec string) string
+}
+
+var (
+	// StatusEnvelope wraps as {"code":0,"msg":"成功","data":{}}
+	StatusEnvelope Envelope = statusEnvelope{}
+	// RawEnvelope writes the reply as the body and the error as errors.Status
+	RawEnvelope Envelope = rawEnvelope{}
+	// ProblemEnvelope writes the reply as the body and the error as RFC 7807 problem details
+	ProblemEnvelope Envelope = problemEnvelope{}
+)
+
+type Header struct {
+	Code   int    `json:"code" xml:"code" msgpack:"code"`
+	Msg    string `json:"msg" xml:"msg" msgpack:"msg"`
+	Reason string `json:"reason,omitempty" xml:"reason,omitempty" msgpack:"reason,omitempty"`
+}
+
+type Response struct {
+	XMLName xml.Name `json:"-" xml:"response" msgpack:"-"`
+	*Header
+	Data interface{} `json:"data" xml:"data" msgpack:"data"`
+}
+
+// MarshalJSON encodes the data by the json codec, so that proto messages keep the protojson format
+func (r *Response) MarshalJSON() ([]byte, error) {
+	data, err := encoding.GetCodec(json.Name).Marshal(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	return stdjson.Marshal(&struct {
+		*Header
+		Data stdjson.RawMessage `json:"data"`
+	}{
+		Header: r.Header,
+		Data:   data,
+	})
+}
+
+type statusEnvelope struct{}
+
+func (statusEnvelope) Name() string {
+	return "status"
+}
+
+func (statusEnvelope) Reply(v interface{}) interface{} {
+	return &Response{
+		Header: &Header{
+			Msg: "成功",
+		},
+		Data: v,
+	}
+}
+
+func (statusEnvelope) Error(e *errors.Error) interface{} {
+	return &Response{
+		Header: &Header{
+			Code:   int(e.Code),
+			Msg:    e.Message,
+			Reason: e.Reason,
+		},
+	}
+}
+
+func (statusEnvelope) ErrorContentType(codec string) string {
+	return SetContentType(codec)
+}
+
+type rawEnvelope struct{}
+
+func (rawEnvelope) Name() string {
+	return "raw"
+}
+
+func (rawEnvelope) Reply(v interface{}) interface{} {
+	return v
+}
+
+func (rawEnvelope) Error(e *errors.Error) interface{} {
+	return &e.Status
+}
+
+func (rawEnvelope) ErrorContentType(codec string) string {
+	return SetContentType(codec)
+}
+
+// Problem is the problem details of RFC 7807 with the reason and metadata extensions
+type Problem struct {
+	XMLName  xml.Name          `json:"-" xml:"urn:ietf:rfc:7807 problem" msgpack:"-"`
+	Type     string            `json:"type" xml:"type" msgpack:"type"`
+	Title    string            `json:"title" xml:"title" msgpack:"title"`
+	Status   int               `json:"status" xml:"status" msgpack:"status"`
+	Detail   string            `json:"detail,omitempty" xml:"detail,omitempty" msgpack:"detail,omitempty"`
+	Reason   string            `json:"reason,omitempty" xml:"reason,omitempty" msgpack:"reason,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty" xml:"-" msgpack:"metadata,omitempty"`
+}
+
+type problemEnvelope struct{}
+
+func (problemEnvelope) Name() string {
+	return "problem"
+}
+
+func (problemEnvelope) Reply(v interface{}) interface{} {
+	return v
+}
+
+func (problemEnvelope) Error(e *errors.Error) interface{} {
+	status := statusCode(int(e.Code))
+	return &Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   e.Message,
+		Reason:   e.Reason,
+		Metadata: e.Metadata,
+	}
+}
+
+func (problemEnvelope) ErrorContentType(codec string) string {
+	switch codec {
+	case json.Name, "xml":
+		return SetContentType("problem+" + codec)
+	}
+	return SetContentType(codec)
+}
+
+// statusCode maps the codes out of the http error range such as errors.UnknownCode to 500
+func statusCode(code int) int {
+	if code < http.StatusBadRequest || code > 599 {
+		return http.StatusInternalServerError
+	}
+	return code
+}
+
+type envelopeKey struct{}
+
+// NewEnvelopeContext returns a new context with the envelope of the response
+func NewEnvelopeContext(ctx context.Context, e Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, e)
+}
+
+// envelopeFromContext returns the envelope set in ctx only
+func envelopeFromContext(ctx context.Context) (Envelope, bool) {
+	e, ok := ctx.Value(envelopeKey{}).(Envelope)
+	return e, ok
+}
+
+// EnvelopeFromContext returns the envelope in ctx, StatusEnvelope by default
+func EnvelopeFromContext(ctx context.Context) Envelope {
+	e, ok := envelopeFromContext(ctx)
+	if !ok {
+		return StatusEnvelope
+	}
+	return e
+}
+
+// RouteEnvelope overrides the envelope of the server for a route
+func RouteEnvelope(e Envelope) handler.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(NewEnvelopeContext(req.Context(), e)))
+		})
+	}
+}
+
+// marshal encodes body by the codec, the proto codec falls back to raw and then to the json codec when body is not a message
+func marshal(codec encoding.Codec, body, raw interface{}) (encoding.Codec, []byte, error) {
+	if codec.Name() == "proto" {
+		_, ok := body.(proto.Message)
+		if !ok {
+			body = raw
+		}
+		_, ok = body.(proto.Message)
+		if !ok {
+			codec = encoding.GetCodec(json.Name)
+		}
+	}
+	data, err := codec.Marshal(body)
+	return codec, data, err
+}
+
+// unwrap decodes the data of the status envelope into v by the codec of the response,
+// the replies of the proto codec are not wrapped
+func unwrap(codec encoding.Codec, body []byte, v interface{}) error {
+	switch codec.Name() {
+	case "proto":
+		return codec.Unmarshal(body, v)
+	case json.Name:
+		// the data is decoded by the json codec, so that proto messages keep the protojson format
+		r := &struct {
+			Data stdjson.RawMessage `json:"data"`
+		}{}
+		err := stdjson.Unmarshal(body, r)
+		if err != nil || len(r.Data) == 0 || string(r.Data) == "null" {
+			return err
+		}
+		return codec.Unmarshal(r.Data, v)
+	case "xml":
+		r := &struct {
+			Data *struct {
+				Inner []byte `xml:",innerxml"`
+			} `xml:"data"`
+		}{}
+		err := xml.Unmarshal(body, r)
+		if err != nil || r.Data == nil {
+			return err
+		}
+		return codec.Unmarshal(append(append([]byte("<data>"), r.Data.Inner...), "</data>"...), v)
+	default:
+		return codec.Unmarshal(body, &Response{Header: &Header{}, Data: v})
+	}
+}
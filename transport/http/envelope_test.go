@@ -0,0 +1,153 @@
+package http
+
+import (
+	"context"
+	"github.com/go-slark/slark/errors"
+	_ "github.com/go-slark/slark/transport"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnvelope(t *testing.T) {
+	cases := []struct {
+		envelope    Envelope
+		accept      string
+		contentType string
+		errorType   string
+		body        string
+	}{
+		{StatusEnvelope, "application/json", "application/json", "application/json", `{"code":0,"msg":"成功","data":{"name":"a"`},
+		{RawEnvelope, "application/json", "application/json", "application/json", `{"name":"a"`},
+		{ProblemEnvelope, "application/json", "application/json", "application/problem+json", `{"name":"a"`},
+		{StatusEnvelope, "application/xml", "application/xml", "application/xml", `<response><code>0</code><msg>成功</msg><data>`},
+		{ProblemEnvelope, "application/xml", "application/xml", "application/problem+xml", `<FieldDescriptorProto>`},
+		{StatusEnvelope, "application/msgpack", "application/msgpack", "application/msgpack", ""},
+		{StatusEnvelope, "application/proto", "application/proto", "application/proto", ""},
+		{ProblemEnvelope, "application/proto", "application/proto", "application/proto", ""},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", c.accept)
+		req = req.WithContext(NewEnvelopeContext(req.Context(), c.envelope))
+
+		rec := httptest.NewRecorder()
+		err := ResponseEncoder(req, rec, &descriptorpb.FieldDescriptorProto{Name: proto.String("a")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != c.contentType || !strings.HasPrefix(rec.Body.String(), c.body) {
+			t.Fatalf("accept:%s, code:%d, content type:%s, body:%s", c.accept, rec.Code, rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+		// the envelope is unwrapped by the json codec
+		if c.accept == "application/json" || c.accept == "application/proto" {
+			out := &descriptorpb.FieldDescriptorProto{}
+			err = ResponseDecoder(context.Background(), rec.Result(), out)
+			if err != nil || out.GetName() != "a" {
+				t.Fatalf("accept:%s, out:%+v, error:%+v", c.accept, out, err)
+			}
+		}
+
+		rec = httptest.NewRecorder()
+		ErrorEncoder(req, rec, errors.NotFound("user not found", "USER_NOT_FOUND").WithMetadata(map[string]string{"id": "1"}))
+		if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != c.errorType {
+			t.Fatalf("accept:%s, code:%d, content type:%s", c.accept, rec.Code, rec.Header().Get("Content-Type"))
+		}
+		e := errors.FromError(ErrorDecoder(context.Background(), rec.Result()))
+		if e.Code != http.StatusNotFound || e.Reason != "USER_NOT_FOUND" || e.Message != "user not found" {
+			t.Fatalf("accept:%s, error:%+v, body:%s", c.accept, e, rec.Body.String())
+		}
+	}
+}
+
+func TestErrorStatusCode(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+	ErrorEncoder(req, rec, errors.New(errors.UnknownCode, "unknown", errors.UnknownReason))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("code:%d", rec.Code)
+	}
+	e := errors.FromError(ErrorDecoder(context.Background(), rec.Result()))
+	if e.Code != errors.UnknownCode {
+		t.Fatalf("error:%+v", e)
+	}
+}
+
+func TestRouteEnvelope(t *testing.T) {
+	srv := NewServer(Address("127.0.0.1:0"), ResponseEnvelope(RawEnvelope))
+	r := NewRouter(srv)
+	r.Handle(http.MethodGet, "/raw", func(ctx *Context) error {
+		return ctx.Result(map[string]string{"name": "a"})
+	})
+	r.Handle(http.MethodGet, "/status", func(ctx *Context) error {
+		return ctx.Result(map[string]string{"name": "a"})
+	}, RouteEnvelope(StatusEnvelope))
+	for path, body := range map[string]string{"/raw": `{"name":"a"}`, "/status": `{"code":0,"msg":"成功","data":{"name":"a"}}`} {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Body.String() != body {
+			t.Fatalf("path:%s, body:%s", path, rec.Body.String())
+		}
+	}
+}
+
+func TestResponseEnvelope(t *testing.T) {
+	reply := map[string]interface{}{"code": 1, "data": "a"}
+	for _, e := range []Envelope{RawEnvelope, ProblemEnvelope} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(NewEnvelopeContext(req.Context(), e))
+		rec := httptest.NewRecorder()
+		err := ResponseEncoder(req, rec, reply)
+		if err != nil || rec.Header().Get("X-Envelope") != e.Name() {
+			t.Fatalf("envelope:%s, header:%s, error:%+v", e.Name(), rec.Header().Get("X-Envelope"), err)
+		}
+		// a plain reply with the code and data keys is not unwrapped
+		out := map[string]interface{}{}
+		err = ResponseDecoder(context.Background(), rec.Result(), &out)
+		if err != nil || out["data"] != "a" || out["code"] != float64(1) {
+			t.Fatalf("envelope:%s, out:%+v, error:%+v", e.Name(), out, err)
+		}
+	}
+
+	// the envelope of the client decides without the header
+	rsp := func() *http.Response {
+		rec := httptest.NewRecorder()
+		rec.Header().Set("Content-Type", "application/json")
+		_, _ = rec.WriteString(`{"code":0,"msg":"成功","data":{"name":"a"}}`)
+		return rec.Result()
+	}
+	out := map[string]interface{}{}
+	err := ResponseDecoder(context.Background(), rsp(), &out)
+	if err != nil || out["msg"] != "成功" {
+		t.Fatalf("out:%+v, error:%+v", out, err)
+	}
+	out = map[string]interface{}{}
+	err = ResponseDecoder(NewEnvelopeContext(context.Background(), StatusEnvelope), rsp(), &out)
+	if err != nil || out["name"] != "a" {
+		t.Fatalf("out:%+v, error:%+v", out, err)
+	}
+
+	// the status envelope is unwrapped by the codec of the response
+	type user struct {
+		Name string `json:"name" xml:"name" msgpack:"name"`
+		Age  int    `json:"age" xml:"age" msgpack:"age"`
+	}
+	for _, accept := range []string{"application/json", "application/xml", "application/msgpack"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		err = ResponseEncoder(req, rec, &user{Name: "a", Age: 18})
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := &user{}
+		rsp := rec.Result()
+		err = ResponseDecoder(context.Background(), rsp, u)
+		if err != nil || u.Name != "a" || u.Age != 18 || rsp.Header.Get("Content-Type") != accept {
+			t.Fatalf("content type:%s, user:%+v, error:%+v", rsp.Header.Get("Content-Type"), u, err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package http
+
+import (
+	"context"
+	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/logger"
+	utils "github.com/go-slark/slark/pkg"
+	"github.com/go-slark/slark/pkg/endpoint"
+	"github.com/go-slark/slark/registry"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// resolver keeps the balancer nodes of a discovery:///service target up to date
+type resolver struct {
+	ctx      context.Context
+	cancel   context.CancelFunc
+	watcher  registry.Watcher
+	balancer node.Balancer
+	scheme   string
+}
+
+func newResolver(discovery registry.Discovery, target string, builder node.Builder, scheme string, tm time.Duration) (*resolver, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != utils.Discovery {
+		return nil, errors.BadRequest("target scheme invalid", "TARGET_SCHEME_INVALID")
+	}
+	r := &resolver{
+		balancer: builder.Build(),
+		scheme:   scheme,
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.watcher, err = discovery.Discover(r.ctx, strings.TrimPrefix(u.Path, "/"))
+	if err != nil {
+		r.cancel()
+		return nil, err
+	}
+	// wait for the initial snapshot
+	cx, cancel := context.WithTimeout(r.ctx, tm)
+	defer cancel()
+	event, err := r.watcher.Next(cx)
+	if err != nil {
+		r.Close()
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, errors.InternalServer("discovery timeout", "DISCOVERY_TIMEOUT")
+		}
+		return nil, err
+	}
+	r.update(event.Services)
+	go r.watch()
+	return r, nil
+}
+
+func (r *resolver) watch() {
+	for {
+		event, err := r.watcher.Next(r.ctx)
+		if err != nil {
+			if errors.Is(err, context.Canceled) || errors.Is(err, registry.ErrWatcherStopped) {
+				return
+			}
+			logger.Log(r.ctx, logger.ErrorLevel, map[string]interface{}{"error": err}, "http resolver watch error")
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			continue
+		}
+		r.update(event.Services)
+	}
+}
+
+func (r *resolver) update(svc []*registry.Service) {
+	mp := map[string]struct{}{}
+	nodes := make([]node.Node, 0, len(svc))
+	for _, s := range svc {
+		addr, err := endpoint.ParseValidAddr(s.Endpoint, r.scheme)
+		if err != nil {
+			continue
+		}
+		_, ok := mp[addr]
+		if ok {
+			continue
+		}
+		mp[addr] = struct{}{}
+		n := &node.WrappedNode{
+			Addr:    addr,
+			Service: s,
+		}
+		w, ok := s.Metadata[utils.Weight]
+		if ok {
+			weight, err := strconv.ParseInt(w, 10, 64)
+			if err == nil {
+				n.Weight = &weight
+			}
+		}
+		nodes = append(nodes, n)
+	}
+	// the deregistered nodes are dropped, the requests fail fast with no available node
+	if len(nodes) == 0 {
+		logger.Log(r.ctx, logger.WarnLevel, map[string]interface{}{"services": len(svc)}, "http resolver no available node")
+	}
+	r.balancer.Save(nodes)
+}
+
+// pick a node and rewrite the request url host
+func (r *resolver) pick(ctx context.Context, u *url.URL, filters ...node.Filter) error {
+	n, err := r.balancer.Pick(ctx, filters...)
+	if err != nil {
+		return errors.ServerUnavailable(err.Error(), "NO_AVAILABLE_NODE")
+	}
+	u.Scheme = r.scheme
+	u.Host = n.Address()
+	return nil
+}
+
+func (r *resolver) Close() {
+	r.cancel()
+	_ = r.watcher.Stop()
+}
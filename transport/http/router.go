@@ -2,10 +2,14 @@ package http
 
 import (
 	"context"
+	"fmt"
 	"github.com/gin-gonic/gin"
+	"github.com/go-slark/slark/errors"
+	"github.com/go-slark/slark/logger"
 	utils "github.com/go-slark/slark/pkg"
 	"github.com/go-slark/slark/transport/http/handler"
 	"net/http"
+	"strings"
 	"sync"
 )
 
@@ -29,23 +33,192 @@ func NewRouter(srv *Server) *Router {
 
 type HandlerFunc func(ctx *Context) error
 
+// Handle registers a gin path such as /uri/:name/:id or a google.api.http template such as /v1/{name=orgs/*}/users,
+// the templates with a custom verb such as /v1/messages:upload share the gin path and are dispatched by the verb
 func (r *Router) Handle(method, path string, hf HandlerFunc, handlers ...handler.Middleware) {
+	path, vars, v := route(path)
 	h := func(ctx *gin.Context) {
 		// /uri/:name/:id
 		mp := make(map[string]string, len(ctx.Params))
 		for _, param := range ctx.Params {
+			if param.Key == verbParam {
+				continue
+			}
 			mp[param.Key] = param.Value
 		}
+		for name, v := range vars {
+			mp[name] = v.build(mp)
+		}
 		ctx.Request = ctx.Request.WithContext(context.WithValue(ctx.Request.Context(), utils.RequestVars, mp))
 		handler.ComposeMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 			c := r.pool.Get().(*Context)
 			c.Set(req, w)
 			if err := hf(c); err != nil {
-				r.srv.codecs.errorEncoder(req, w, err)
+				if !ctx.Writer.Written() {
+					r.srv.codecs.errorEncoder(req, w, err)
+				} else {
+					r.srv.logger.Log(req.Context(), logger.ErrorLevel, map[string]interface{}{"error": fmt.Sprintf("%+v", err)}, "http response already written")
+				}
 			}
 			c.Set(nil, nil)
 			r.pool.Put(c)
 		}), handlers...).ServeHTTP(ctx.Writer, ctx.Request)
 	}
-	r.srv.engine.Handle(method, r.srv.basePath+path, h)
+	path = r.srv.basePath + path
+	key := method + " " + path
+	// the routes are kept by the server, the routers of the services share the gin path
+	routes, ok := r.srv.routes[key]
+	for _, route := range routes {
+		if route.verb == v || route.verb != nil && v != nil && route.verb.name == v.name {
+			panic(fmt.Sprintf("handlers are already registered for path '%s'", path))
+		}
+	}
+	r.srv.routes[key] = append(routes, &verbRoute{verb: v, handler: h})
+	if ok {
+		return
+	}
+	srv := r.srv
+	srv.engine.Handle(method, path, func(ctx *gin.Context) {
+		srv.dispatch(ctx, srv.routes[key])
+	})
+}
+
+// dispatch serves by the route matching the verb, or else by the route without verb
+func (s *Server) dispatch(ctx *gin.Context, routes []*verbRoute) {
+	for _, route := range routes {
+		if route.verb != nil && route.verb.match(ctx.Params) {
+			route.handler(ctx)
+			return
+		}
+	}
+	for _, route := range routes {
+		if route.verb == nil {
+			route.handler(ctx)
+			return
+		}
+	}
+	s.codecs.errorEncoder(ctx.Request, ctx.Writer, errors.NotFound(http.StatusText(http.StatusNotFound), errors.UnknownReason))
+}
+
+// verbParam is the gin param holding the verb following a literal segment, /v1/messages:upload -> /v1/messages:_verb
+const verbParam = "_verb"
+
+// verb of a templated path, it is the suffix of the last gin param
+type verb struct {
+	name    string
+	param   string
+	literal bool // the param holds the verb only
+}
+
+// match trims the verb off the param, the verb following a variable leaves a non empty value
+func (v *verb) match(params gin.Params) bool {
+	suffix := ":" + v.name
+	for i, param := range params {
+		if param.Key != v.param {
+			continue
+		}
+		if v.literal {
+			return param.Value == suffix
+		}
+		value := strings.TrimSuffix(param.Value, suffix)
+		if len(value) == len(param.Value) || len(strings.Trim(value, "/")) == 0 {
+			return false
+		}
+		params[i].Value = value
+		return true
+	}
+	return false
+}
+
+type verbRoute struct {
+	verb    *verb
+	handler gin.HandlerFunc
+}
+
+// variable of a templated path {name=orgs/*}
+type variable struct {
+	segments []string // literal or gin param name
+	params   map[string]struct{}
+}
+
+func (v *variable) build(mp map[string]string) string {
+	values := make([]string, 0, len(v.segments))
+	for _, seg := range v.segments {
+		_, ok := v.params[seg]
+		if !ok {
+			values = append(values, seg)
+			continue
+		}
+		values = append(values, strings.TrimPrefix(mp[seg], "/"))
+		delete(mp, seg)
+	}
+	return strings.Join(values, "/")
+}
+
+// splitVerb splits the custom verb off the template, the colon of a gin param such as /uri/:name is not a verb
+func splitVerb(path string) (string, string) {
+	depth := 0
+	for i := len(path) - 1; i > 0; i-- {
+		switch path[i] {
+		case '}':
+			depth++
+		case '{':
+			depth--
+		case '/':
+			if depth == 0 {
+				return path, ""
+			}
+		case ':':
+			if depth == 0 && path[i-1] != '/' {
+				return path[:i], path[i+1:]
+			}
+		}
+	}
+	return path, ""
+}
+
+// route converts the google.api.http template into the gin path: {id} -> :id, {name=orgs/*} -> orgs/:name, {name=**} -> *name,
+// the verb is held by the last param, or by _verb following the last literal segment: messages:upload -> messages:_verb
+func route(path string) (string, map[string]*variable, *verb) {
+	path, name := splitVerb(path)
+	vars := map[string]*variable{}
+	path = pathVar.ReplaceAllStringFunc(path, func(s string) string {
+		m := pathVar.FindStringSubmatch(s)
+		name := strings.TrimSpace(m[1])
+		pattern := strings.TrimPrefix(m[2], "=")
+		if len(pattern) == 0 || pattern == "*" {
+			return ":" + name
+		}
+		v := &variable{params: map[string]struct{}{}}
+		segments := strings.Split(pattern, "/")
+		for i, seg := range segments {
+			param := fmt.Sprintf("%s_%d", name, i)
+			switch seg {
+			case "*":
+				segments[i] = ":" + param
+			case "**":
+				segments[i] = "*" + param
+			default:
+				v.segments = append(v.segments, seg)
+				continue
+			}
+			v.segments = append(v.segments, param)
+			v.params[param] = struct{}{}
+		}
+		vars[name] = v
+		return strings.Join(segments, "/")
+	})
+	if len(name) == 0 {
+		return path, vars, nil
+	}
+	v := &verb{name: name}
+	seg := path[strings.LastIndex(path, "/")+1:]
+	if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+		v.param = seg[1:]
+	} else {
+		v.param = verbParam
+		v.literal = true
+		path += ":" + verbParam
+	}
+	return path, vars, v
 }
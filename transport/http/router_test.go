@@ -0,0 +1,108 @@
+package http
+
+import (
+	utils "github.com/go-slark/slark/pkg"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoute(t *testing.T) {
+	cases := []struct {
+		template string
+		path     string
+		params   map[string]string
+		vars     map[string]string
+	}{
+		{"/v1/users/{id}", "/v1/users/:id", map[string]string{"id": "1"}, map[string]string{"id": "1"}},
+		{"/v1/users/{user.id}", "/v1/users/:user.id", map[string]string{"user.id": "1"}, map[string]string{"user.id": "1"}},
+		{"/v1/{parent=orgs/*}/users", "/v1/orgs/:parent_1/users", map[string]string{"parent_1": "7"}, map[string]string{"parent": "orgs/7"}},
+		{"/v1/{name=shelves/*/books/*}", "/v1/shelves/:name_1/books/:name_3", map[string]string{"name_1": "1", "name_3": "2"}, map[string]string{"name": "shelves/1/books/2"}},
+		{"/v1/files/{path=**}", "/v1/files/*path_0", map[string]string{"path_0": "/a/b"}, map[string]string{"path": "a/b"}},
+	}
+	for _, c := range cases {
+		path, vars, _ := route(c.template)
+		if path != c.path {
+			t.Fatalf("template:%s, path:%s", c.template, path)
+		}
+		mp := map[string]string{}
+		for k, v := range c.params {
+			mp[k] = v
+		}
+		for name, v := range vars {
+			mp[name] = v.build(mp)
+		}
+		if len(mp) != len(c.vars) {
+			t.Fatalf("template:%s, vars:%+v", c.template, mp)
+		}
+		for k, v := range c.vars {
+			if mp[k] != v {
+				t.Fatalf("template:%s, vars:%+v", c.template, mp)
+			}
+		}
+	}
+}
+
+func TestRouteVerb(t *testing.T) {
+	srv := NewServer(Address("127.0.0.1:0"), ResponseEnvelope(RawEnvelope))
+	r := NewRouter(srv)
+	handle := func(path, name string) {
+		r.Handle(http.MethodPost, path, func(ctx *Context) error {
+			vars, _ := ctx.Context().Value(utils.RequestVars).(map[string]string)
+			return ctx.Result(map[string]string{"route": name, "name": vars["name"]})
+		})
+	}
+	handle("/v1/messages:upload", "upload")
+	handle("/v1/messages:cancel", "cancel")
+	handle("/v1/messages", "create")
+	handle("/v1/messages/{name}", "update")
+	handle("/v1/messages/{name}:cancel", "cancel")
+	handle("/v1/{name=files/**}:copy", "copy")
+
+	cases := map[string]string{
+		"/v1/messages:upload":    `{"name":"","route":"upload"}`,
+		"/v1/messages:cancel":    `{"name":"","route":"cancel"}`,
+		"/v1/messages":           `{"name":"","route":"create"}`,
+		"/v1/messages/1":         `{"name":"1","route":"update"}`,
+		"/v1/messages/1:cancel":  `{"name":"1","route":"cancel"}`,
+		"/v1/files/a/b:copy":     `{"name":"files/a/b","route":"copy"}`,
+		"/v1/messagesXYZ":        "",
+		"/v1/messages:unknown":   "",
+		"/v1/messages/1:unknown": `{"name":"1:unknown","route":"update"}`,
+		"/v1/files/a/b":          "",
+	}
+	for path, body := range cases {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if len(body) == 0 {
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("path:%s, code:%d, body:%s", path, rec.Code, rec.Body.String())
+			}
+			continue
+		}
+		if rec.Code != http.StatusOK || rec.Body.String() != body {
+			t.Fatalf("path:%s, code:%d, body:%s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	// the routers of the services share the path
+	NewRouter(srv).Handle(http.MethodPost, "/v1/messages:archive", func(ctx *Context) error {
+		return ctx.Result(map[string]string{"route": "archive"})
+	})
+	for path, body := range map[string]string{"/v1/messages:archive": `{"route":"archive"}`, "/v1/messages:upload": `{"name":"","route":"upload"}`} {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		if rec.Code != http.StatusOK || rec.Body.String() != body {
+			t.Fatalf("path:%s, code:%d, body:%s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Fatal("duplicate verb expected panic")
+		}
+	}()
+	NewRouter(srv).Handle(http.MethodPost, "/v1/messages:upload", func(ctx *Context) error {
+		return nil
+	})
+}
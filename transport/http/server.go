@@ -11,6 +11,7 @@ import (
 	"github.com/go-slark/slark/middleware/flexible/breaker"
 	"github.com/go-slark/slark/middleware/flexible/shedding"
 	"github.com/go-slark/slark/middleware/logging"
+	"github.com/go-slark/slark/middleware/metadata"
 	"github.com/go-slark/slark/middleware/metrics"
 	"github.com/go-slark/slark/middleware/recovery"
 	"github.com/go-slark/slark/middleware/tracing"
@@ -21,9 +22,11 @@ import (
 	"github.com/go-slark/slark/transport"
 	"github.com/go-slark/slark/transport/http/handler"
 	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
 	"net"
 	"net/http"
 	"net/url"
+	"sync"
 )
 
 type Server struct {
@@ -38,9 +41,15 @@ type Server struct {
 	basePath string
 	enable   int64
 	engine   *gin.Engine
+	routes   map[string][]*verbRoute // the routes of a gin path dispatched by the custom verbs
 	logger   logger.Logger
 	codecs   *Codecs
+	envelope Envelope
 	headers  []string
+	unary    []grpc.UnaryServerInterceptor
+	stream   []grpc.StreamServerInterceptor
+	ready    chan struct{}
+	once     sync.Once
 }
 
 type ServerOption func(server *Server)
@@ -57,6 +66,13 @@ func Address(addr string) ServerOption {
 	}
 }
 
+// Listener serves on l instead of listening on the address, such as the listener of mux.Server
+func Listener(l net.Listener) ServerOption {
+	return func(s *Server) {
+		s.listener = l
+	}
+}
+
 func TLS(tls *tls.Config) ServerOption {
 	return func(s *Server) {
 		s.tls = tls
@@ -111,6 +127,28 @@ func RspCodec(rc func(*http.Request, http.ResponseWriter, interface{}) error) Se
 	}
 }
 
+// ResponseEnvelope sets the envelope of the replies and errors, StatusEnvelope by default
+func ResponseEnvelope(e Envelope) ServerOption {
+	return func(server *Server) {
+		server.envelope = e
+	}
+}
+
+// UnaryInterceptor sets the gRPC interceptors of the transcoded unary calls, they run inside the middlewares,
+// the interceptors of a grpc server are not run by the transcoded calls unless set here as well
+func UnaryInterceptor(u []grpc.UnaryServerInterceptor) ServerOption {
+	return func(server *Server) {
+		server.unary = u
+	}
+}
+
+// StreamInterceptor sets the gRPC interceptors of the transcoded streaming calls, they run inside the middlewares
+func StreamInterceptor(s []grpc.StreamServerInterceptor) ServerOption {
+	return func(server *Server) {
+		server.stream = s
+	}
+}
+
 func Headers(headers []string) ServerOption {
 	return func(server *Server) {
 		server.headers = headers
@@ -127,6 +165,7 @@ func NewServer(opts ...ServerOption) *Server {
 		Server:   &http.Server{},
 		handlers: []handler.Middleware{handler.CORS()},
 		engine:   engine,
+		routes:   map[string][]*verbRoute{},
 		codecs: &Codecs{
 			bodyDecoder:  RequestBodyDecoder,
 			varsDecoder:  RequestVarsDecoder,
@@ -134,9 +173,11 @@ func NewServer(opts ...ServerOption) *Server {
 			rspEncoder:   ResponseEncoder,
 			errorEncoder: ErrorEncoder,
 		},
-		headers: []string{utils.Token, utils.Authorization, utils.UserAgent, utils.XForwardedMethod, utils.XForwardedIP, utils.XForwardedURI, utils.Extension},
-		mws:     []middleware.Middleware{},
-		enable:  0x63, // low -> high
+		envelope: StatusEnvelope,
+		headers:  []string{utils.Token, utils.Authorization, utils.UserAgent, utils.XForwardedMethod, utils.XForwardedIP, utils.XForwardedURI, utils.Extension},
+		mws:      []middleware.Middleware{},
+		enable:   0x63, // low -> high
+		ready:    make(chan struct{}),
 	}
 	srv.mws = []middleware.Middleware{
 		tracing.Trace(trace.SpanKindServer),
@@ -146,6 +187,7 @@ func NewServer(opts ...ServerOption) *Server {
 		shedding.Limit(),
 		recovery.Recovery(srv.logger),
 		validate.Validate(),
+		metadata.Metadata(middleware.Server), // opt-in by the bit 0x80 of Enable
 	}
 	for _, o := range opts {
 		o(srv)
@@ -159,7 +201,8 @@ func NewServer(opts ...ServerOption) *Server {
 				Req:       Carrier(r.Header),
 				Rsp:       Carrier{},
 			}
-			r = r.WithContext(transport.NewServerContext(r.Context(), trans))
+			ctx := NewEnvelopeContext(r.Context(), srv.envelope)
+			r = r.WithContext(transport.NewServerContext(ctx, trans))
 			handler.ServeHTTP(w, r)
 		})
 	})
@@ -169,6 +212,9 @@ func NewServer(opts ...ServerOption) *Server {
 }
 
 func (s *Server) listen() error {
+	if s.listener != nil {
+		return nil
+	}
 	l, err := net.Listen(s.network, s.address)
 	if err != nil {
 		return err
@@ -197,6 +243,9 @@ func (s *Server) Start() error {
 	if s.err != nil {
 		return s.err
 	}
+	s.once.Do(func() {
+		close(s.ready)
+	})
 	var err error
 	if s.tls != nil {
 		err = s.ServeTLS(s.listener, "", "")
@@ -212,3 +261,22 @@ func (s *Server) Start() error {
 func (s *Server) Stop(ctx context.Context) error {
 	return s.Shutdown(ctx)
 }
+
+// SetBaseContext sets the base context of the requests served, it is called by the app before Start
+func (s *Server) SetBaseContext(ctx context.Context) {
+	s.BaseContext = func(net.Listener) context.Context {
+		return ctx
+	}
+}
+
+func (s *Server) Ready(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	select {
+	case <-s.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,184 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/go-slark/slark/encoding"
+	"github.com/go-slark/slark/encoding/json"
+	"github.com/go-slark/slark/errors"
+	utils "github.com/go-slark/slark/pkg"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	EventStream = "text/event-stream"
+	NDJSON      = "application/x-ndjson"
+)
+
+// Flush sends the buffered response to the client
+func (c *Context) Flush() error {
+	return http.NewResponseController(c.rsp).Flush()
+}
+
+// Done is closed when the client disconnects
+func (c *Context) Done() <-chan struct{} {
+	return c.req.Context().Done()
+}
+
+// ServerStream writes the messages of a server streaming rpc as SSE or NDJSON negotiated by Accept
+type ServerStream struct {
+	ctx     context.Context
+	req     *http.Request
+	rsp     http.ResponseWriter
+	codec   encoding.Codec
+	sse     bool
+	started *bool
+}
+
+func (c *Context) ServerStream() *ServerStream {
+	return &ServerStream{
+		ctx:     c.ctx,
+		req:     c.req,
+		rsp:     c.rsp,
+		codec:   encoding.GetCodec(json.Name),
+		sse:     strings.Contains(c.req.Header.Get(utils.Accept), EventStream),
+		started: new(bool),
+	}
+}
+
+// WithContext returns a shallow copy of the stream with ctx, such as the ctx passed through middlewares
+func (s *ServerStream) WithContext(ctx context.Context) *ServerStream {
+	stream := *s
+	stream.ctx = ctx
+	return &stream
+}
+
+func (s *ServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *ServerStream) start() {
+	if *s.started {
+		return
+	}
+	*s.started = true
+	header := s.rsp.Header()
+	if s.sse {
+		header.Set(utils.ContentType, EventStream)
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Connection", "keep-alive")
+	} else {
+		header.Set(utils.ContentType, NDJSON)
+	}
+	header.Set("X-Accel-Buffering", "no")
+	s.rsp.WriteHeader(http.StatusOK)
+}
+
+func (s *ServerStream) write(event string, data []byte) error {
+	// client disconnected
+	err := s.req.Context().Err()
+	if err != nil {
+		return errors.New(errors.ClientClosed, err.Error(), "CLIENT_CLOSED").WithError(err)
+	}
+	s.start()
+	var buf bytes.Buffer
+	if s.sse {
+		if len(event) > 0 {
+			buf.WriteString("event: " + event + "\n")
+		}
+		buf.WriteString("data: ")
+		buf.Write(data)
+		buf.WriteString("\n\n")
+	} else {
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	_, err = s.rsp.Write(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	return http.NewResponseController(s.rsp).Flush()
+}
+
+// Send writes a message and flushes it
+func (s *ServerStream) Send(v interface{}) error {
+	data, err := s.codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.write("", data)
+}
+
+// Finish ends the stream, the error is written as an error event once the stream started,
+// the error marshaling or writing the event is returned
+func (s *ServerStream) Finish(err error) error {
+	if err == nil || !*s.started {
+		return err
+	}
+	e := errors.FromError(err)
+	header := &Header{
+		Code:   int(e.Code),
+		Msg:    e.Message,
+		Reason: e.Reason,
+	}
+	var data []byte
+	if s.sse {
+		data, err = s.codec.Marshal(header)
+	} else {
+		data, err = s.codec.Marshal(map[string]*Header{"error": header})
+	}
+	if err != nil {
+		return err
+	}
+	return s.write("error", data)
+}
+
+// ClientStream reads the messages of a client streaming rpc from a NDJSON request body
+type ClientStream struct {
+	ctx    context.Context
+	reader *bufio.Reader
+	codec  encoding.Codec
+}
+
+func (c *Context) ClientStream() *ClientStream {
+	return &ClientStream{
+		ctx:    c.ctx,
+		reader: bufio.NewReader(c.req.Body),
+		codec:  encoding.GetCodec(json.Name),
+	}
+}
+
+// WithContext returns a shallow copy of the stream with ctx, such as the ctx passed through middlewares
+func (s *ClientStream) WithContext(ctx context.Context) *ClientStream {
+	stream := *s
+	stream.ctx = ctx
+	return &stream
+}
+
+func (s *ClientStream) Context() context.Context {
+	return s.ctx
+}
+
+// Recv decodes the next line into v, io.EOF is returned at the end of the body
+func (s *ClientStream) Recv(v interface{}) error {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			e := s.codec.Unmarshal(line, v)
+			if e != nil {
+				return errors.BadRequest(e.Error(), "CLIENT_STREAM_DECODE").WithError(e)
+			}
+			return nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				return io.EOF
+			}
+			return errors.BadRequest(err.Error(), "CLIENT_STREAM_READ").WithError(err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"github.com/go-slark/slark/encoding"
+	"github.com/go-slark/slark/errors"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerStream(t *testing.T) {
+	cases := []struct {
+		accept      string
+		contentType string
+		body        string
+	}{
+		{EventStream, EventStream, "data: {\"name\":\"a\"}\n\nevent: error\ndata: {\"code\":404,\"msg\":\"gone\",\"reason\":\"GONE\"}\n\n"},
+		{"", NDJSON, "{\"name\":\"a\"}\n{\"error\":{\"code\":404,\"msg\":\"gone\",\"reason\":\"GONE\"}}\n"},
+	}
+	for _, c := range cases {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Accept", c.accept)
+		ctx := &Context{}
+		ctx.Set(req, rec)
+		stream := ctx.ServerStream()
+		err := stream.WithContext(ctx.Context()).Send(map[string]string{"name": "a"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = stream.Finish(errors.NotFound("gone", "GONE"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Header().Get("Content-Type") != c.contentType || rec.Body.String() != c.body {
+			t.Fatalf("content type:%s, body:%q", rec.Header().Get("Content-Type"), rec.Body.String())
+		}
+	}
+
+	// the error is returned as is before the stream started
+	ctx := &Context{}
+	ctx.Set(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	err := ctx.ServerStream().Finish(errors.NotFound("gone", "GONE"))
+	if errors.FromError(err).Reason != "GONE" {
+		t.Fatalf("error:%+v", err)
+	}
+
+	// the error marshaling the error event is returned
+	ctx.Set(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	stream := ctx.ServerStream()
+	_ = stream.Send(map[string]string{"name": "a"})
+	stream.codec = failCodec{Codec: stream.codec}
+	err = stream.Finish(errors.NotFound("gone", "GONE"))
+	if err == nil || errors.Reason(err) == "GONE" {
+		t.Fatalf("error:%+v", err)
+	}
+}
+
+type failCodec struct {
+	encoding.Codec
+}
+
+func (failCodec) Marshal(v interface{}) ([]byte, error) {
+	return nil, io.ErrUnexpectedEOF
+}
+
+func TestClientStream(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{\"name\":\"a\"}\n\n{\"name\":\"b\"}"))
+	ctx := &Context{}
+	ctx.Set(req, httptest.NewRecorder())
+	stream := ctx.ClientStream()
+	var names []string
+	for {
+		msg := &descriptorpb.FieldDescriptorProto{}
+		err := stream.Recv(msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, msg.GetName())
+	}
+	if strings.Join(names, ",") != "a,b" {
+		t.Fatalf("names:%+v", names)
+	}
+
+	stream = (&Context{req: httptest.NewRequest(http.MethodPost, "/", strings.NewReader("{x}\n"))}).ClientStream()
+	err := stream.Recv(&descriptorpb.FieldDescriptorProto{})
+	if errors.FromError(err).Code != http.StatusBadRequest || errors.Reason(err) != "CLIENT_STREAM_DECODE" {
+		t.Fatalf("error:%+v", err)
+	}
+}
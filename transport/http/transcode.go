@@ -0,0 +1,348 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-slark/slark/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+var _ grpc.ServiceRegistrar = (*Server)(nil)
+
+// RegisterService implements grpc.ServiceRegistrar, so that a gRPC service such as pb.RegisterGreeterServer(srv, impl)
+// is transcoded over http/json by the google.api.http annotations of the registered proto descriptors,
+// a method without annotation is served as POST /package.Service/Method, bidi streaming is not supported,
+// the go types of the input and output messages must be registered, such as by importing the generated pb package,
+// the calls run the middlewares of the server and then the interceptors set by UnaryInterceptor and StreamInterceptor
+func (s *Server) RegisterService(desc *grpc.ServiceDesc, impl interface{}) {
+	if impl != nil {
+		ht := reflect.TypeOf(desc.HandlerType).Elem()
+		if !reflect.TypeOf(impl).Implements(ht) {
+			panic(fmt.Sprintf("http: RegisterService found the handler of type %v that does not satisfy %v", reflect.TypeOf(impl), ht))
+		}
+	}
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(protoreflect.FullName(desc.ServiceName))
+	if err != nil {
+		panic(fmt.Sprintf("http: RegisterService found no descriptor of %s: %v", desc.ServiceName, err))
+	}
+	sd, ok := d.(protoreflect.ServiceDescriptor)
+	if !ok {
+		panic(fmt.Sprintf("http: RegisterService found %s is not a service", desc.ServiceName))
+	}
+
+	r := NewRouter(s)
+	for i := range desc.Methods {
+		md := sd.Methods().ByName(protoreflect.Name(desc.Methods[i].MethodName))
+		if md == nil {
+			continue
+		}
+		t := &transcoder{
+			method: &desc.Methods[i],
+			impl:   impl,
+			desc:   md,
+			in:     messageType(md.Input()),
+			out:    messageType(md.Output()),
+			full:   fmt.Sprintf("/%s/%s", desc.ServiceName, md.Name()),
+		}
+		t.handle(r)
+	}
+	for i := range desc.Streams {
+		md := sd.Methods().ByName(protoreflect.Name(desc.Streams[i].StreamName))
+		if md == nil || (md.IsStreamingClient() && md.IsStreamingServer()) {
+			continue
+		}
+		t := &transcoder{
+			stream: &desc.Streams[i],
+			impl:   impl,
+			desc:   md,
+			in:     messageType(md.Input()),
+			out:    messageType(md.Output()),
+			full:   fmt.Sprintf("/%s/%s", desc.ServiceName, md.Name()),
+		}
+		t.handle(r)
+	}
+}
+
+// transcoder serves a gRPC method by the http rules of the method
+type transcoder struct {
+	method *grpc.MethodDesc
+	stream *grpc.StreamDesc
+	impl   interface{}
+	desc   protoreflect.MethodDescriptor
+	in     protoreflect.MessageType
+	out    protoreflect.MessageType
+	full   string
+}
+
+func (t *transcoder) handle(r *Router) {
+	rule, ok := proto.GetExtension(t.desc.Options(), annotations.E_Http).(*annotations.HttpRule)
+	if !ok || rule == nil {
+		rule = &annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Post{Post: t.full},
+			Body:    "*",
+		}
+	}
+	for _, bind := range append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...) {
+		method, path := pattern(bind)
+		if len(path) == 0 {
+			continue
+		}
+		r.Handle(method, path, t.handler(bind))
+	}
+}
+
+func pattern(rule *annotations.HttpRule) (string, string) {
+	switch p := rule.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		return http.MethodGet, p.Get
+	case *annotations.HttpRule_Put:
+		return http.MethodPut, p.Put
+	case *annotations.HttpRule_Post:
+		return http.MethodPost, p.Post
+	case *annotations.HttpRule_Delete:
+		return http.MethodDelete, p.Delete
+	case *annotations.HttpRule_Patch:
+		return http.MethodPatch, p.Patch
+	case *annotations.HttpRule_Custom:
+		return strings.ToUpper(p.Custom.GetKind()), p.Custom.GetPath()
+	}
+	return "", ""
+}
+
+// messageType returns the registered go type of the message, the handlers decode into it
+func messageType(md protoreflect.MessageDescriptor) protoreflect.MessageType {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(md.FullName())
+	if err != nil {
+		panic(fmt.Sprintf("http: RegisterService found no registered go type of %s: %v", md.FullName(), err))
+	}
+	return mt
+}
+
+// bind decodes the query, the body and the path vars of the request into in by the rule
+func (t *transcoder) bind(ctx *Context, rule *annotations.HttpRule, in proto.Message) error {
+	var err error
+	if rule.Body != "*" {
+		err = ctx.ShouldBindQuery(in)
+		if err != nil {
+			return err
+		}
+	}
+	switch rule.Body {
+	case "":
+	case "*":
+		err = ctx.ShouldBind(in)
+	default:
+		var body proto.Message
+		body, err = subMessage(in.ProtoReflect(), rule.Body, true)
+		if err == nil {
+			err = ctx.ShouldBind(body)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return ctx.ShouldBindURI(in)
+}
+
+// subMessage returns the message field of a path such as a.b, the fields are allocated if mutable
+func subMessage(msg protoreflect.Message, path string, mutable bool) (proto.Message, error) {
+	for _, name := range strings.Split(path, ".") {
+		fd := msg.Descriptor().Fields().ByName(protoreflect.Name(name))
+		if fd == nil || fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, errors.InternalServer(fmt.Sprintf("field %s of %s is not a message", path, msg.Descriptor().FullName()), "TRANSCODE_FIELD_INVALID")
+		}
+		if mutable {
+			msg = msg.Mutable(fd).Message()
+		} else {
+			msg = msg.Get(fd).Message()
+		}
+	}
+	return msg.Interface(), nil
+}
+
+func (t *transcoder) handler(rule *annotations.HttpRule) HandlerFunc {
+	return func(ctx *Context) error {
+		var in proto.Message
+		if t.stream == nil || !t.stream.ClientStreams {
+			in = t.in.New().Interface()
+			err := t.bind(ctx, rule, in)
+			if err != nil {
+				return err
+			}
+		}
+		c := metadata.NewIncomingContext(ctx.Context(), incomingMD(ctx.req.Header))
+		if t.stream != nil {
+			return t.serveStream(ctx, c, rule, in)
+		}
+		out, err := t.method.Handler(t.impl, c, func(v interface{}) error {
+			proto.Merge(v.(proto.Message), in)
+			return nil
+		}, func(c context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return ctx.Handle(func(c context.Context, req interface{}) (interface{}, error) {
+				return chainUnary(ctx.router.srv.unary)(c, req, info, handler)
+			})(c, req)
+		})
+		if err != nil {
+			return err
+		}
+		return t.result(ctx, rule, out)
+	}
+}
+
+func (t *transcoder) result(ctx *Context, rule *annotations.HttpRule, out interface{}) error {
+	if len(rule.ResponseBody) == 0 {
+		return ctx.Result(out)
+	}
+	msg, ok := out.(proto.Message)
+	if !ok {
+		return ctx.Result(out)
+	}
+	body, err := subMessage(msg.ProtoReflect(), rule.ResponseBody, false)
+	if err != nil {
+		return err
+	}
+	return ctx.Result(body)
+}
+
+func (t *transcoder) serveStream(ctx *Context, c context.Context, rule *annotations.HttpRule, in proto.Message) error {
+	ss := &serverStream{in: in}
+	if t.stream.ClientStreams {
+		ss.client = ctx.ClientStream()
+	}
+	if t.stream.ServerStreams {
+		ss.server = ctx.ServerStream()
+	}
+	_, err := ctx.Handle(func(c context.Context, _ interface{}) (interface{}, error) {
+		ss.ctx = c
+		info := &grpc.StreamServerInfo{FullMethod: t.full, IsClientStream: t.stream.ClientStreams, IsServerStream: t.stream.ServerStreams}
+		return nil, chainStream(ctx.router.srv.stream)(t.impl, ss, info, t.stream.Handler)
+	})(c, in)
+	if ss.server != nil {
+		return ss.server.Finish(err)
+	}
+	if err != nil {
+		return err
+	}
+	if ss.out == nil {
+		ss.out = t.out.New().Interface()
+	}
+	return t.result(ctx, rule, ss.out)
+}
+
+// reserved headers are not passed as the incoming metadata, the hop-by-hop headers and the headers of the http request
+var reserved = map[string]struct{}{
+	"Connection":          {},
+	"Keep-Alive":          {},
+	"Proxy-Authenticate":  {},
+	"Proxy-Authorization": {},
+	"Proxy-Connection":    {},
+	"Te":                  {},
+	"Trailer":             {},
+	"Transfer-Encoding":   {},
+	"Upgrade":             {},
+	"Content-Length":      {},
+	"Host":                {},
+}
+
+// incomingMD returns the headers as the incoming metadata except the reserved, the grpc- and the Connection listed headers
+func incomingMD(header http.Header) metadata.MD {
+	hop := map[string]struct{}{}
+	for _, v := range header.Values("Connection") {
+		for _, k := range strings.Split(v, ",") {
+			hop[http.CanonicalHeaderKey(strings.TrimSpace(k))] = struct{}{}
+		}
+	}
+	md := metadata.MD{}
+	for k, v := range header {
+		_, ok := reserved[k]
+		if ok || strings.HasPrefix(k, "Grpc-") {
+			continue
+		}
+		_, ok = hop[k]
+		if ok {
+			continue
+		}
+		md.Append(k, v...)
+	}
+	return md
+}
+
+// chainUnary composes the interceptors into one, the first is the outermost
+func chainUnary(interceptors []grpc.UnaryServerInterceptor) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(ctx context.Context, req interface{}) (interface{}, error) {
+				return interceptor(ctx, req, info, next)
+			}
+		}
+		return handler(ctx, req)
+	}
+}
+
+// chainStream composes the interceptors into one, the first is the outermost
+func chainStream(interceptors []grpc.StreamServerInterceptor) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		for i := len(interceptors) - 1; i >= 0; i-- {
+			interceptor, next := interceptors[i], handler
+			handler = func(srv interface{}, ss grpc.ServerStream) error {
+				return interceptor(srv, ss, info, next)
+			}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// serverStream adapts the ServerStream and ClientStream of http to grpc.ServerStream
+type serverStream struct {
+	ctx    context.Context
+	in     proto.Message
+	client *ClientStream
+	server *ServerStream
+	out    interface{}
+}
+
+func (s *serverStream) SetHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *serverStream) SendHeader(metadata.MD) error {
+	return nil
+}
+
+func (s *serverStream) SetTrailer(metadata.MD) {}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// SendMsg writes a message of server streaming, the reply of client streaming is kept for the response
+func (s *serverStream) SendMsg(m interface{}) error {
+	if s.server == nil {
+		s.out = m
+		return nil
+	}
+	return s.server.WithContext(s.ctx).Send(m)
+}
+
+// RecvMsg reads the messages of client streaming, or the bound request once for server streaming
+func (s *serverStream) RecvMsg(m interface{}) error {
+	if s.client != nil {
+		return s.client.Recv(m)
+	}
+	if s.in == nil {
+		return io.EOF
+	}
+	proto.Merge(m.(proto.Message), s.in)
+	s.in = nil
+	return nil
+}
@@ -0,0 +1,300 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/go-slark/slark/errors"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// registerItemService registers the descriptor of
+//
+//	service ItemService {
+//	  rpc GetItem(GetItemRequest) returns (Item) { get: "/v1/items/{id}" }
+//	  rpc UpdateItem(UpdateItemRequest) returns (UpdateItemReply) { patch: "/v1/items/{item.id}" body: "item" response_body: "item" }
+//	  rpc Ping(Item) returns (Item) {}
+//	  rpc WatchItems(GetItemRequest) returns (stream Item) { get: "/v1/items:watch" }
+//	}
+func registerItemService(t *testing.T) protoreflect.FileDescriptor {
+	const name = "slark.transcode.test.ItemService"
+	d, err := protoregistry.GlobalFiles.FindDescriptorByName(name)
+	if err == nil {
+		return d.ParentFile()
+	}
+	field := func(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     typ.Enum(),
+		}
+		if len(typeName) > 0 {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	method := func(name, in, out string, rule *annotations.HttpRule, serverStreaming bool) *descriptorpb.MethodDescriptorProto {
+		m := &descriptorpb.MethodDescriptorProto{
+			Name:            proto.String(name),
+			InputType:       proto.String(".slark.transcode.test." + in),
+			OutputType:      proto.String(".slark.transcode.test." + out),
+			ServerStreaming: proto.Bool(serverStreaming),
+		}
+		if rule != nil {
+			m.Options = &descriptorpb.MethodOptions{}
+			proto.SetExtension(m.Options, annotations.E_Http, rule)
+		}
+		return m
+	}
+	str, boolean, msg := descriptorpb.FieldDescriptorProto_TYPE_STRING, descriptorpb.FieldDescriptorProto_TYPE_BOOL, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("slark/transcode/test/item.proto"),
+		Package: proto.String("slark.transcode.test"),
+		Syntax:  proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("Item"), Field: []*descriptorpb.FieldDescriptorProto{field("id", 1, str, ""), field("name", 2, str, "")}},
+			{Name: proto.String("GetItemRequest"), Field: []*descriptorpb.FieldDescriptorProto{field("id", 1, str, ""), field("verbose", 2, boolean, "")}},
+			{Name: proto.String("UpdateItemRequest"), Field: []*descriptorpb.FieldDescriptorProto{field("item", 1, msg, ".slark.transcode.test.Item"), field("mask", 2, str, "")}},
+			{Name: proto.String("UpdateItemReply"), Field: []*descriptorpb.FieldDescriptorProto{field("item", 1, msg, ".slark.transcode.test.Item")}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("ItemService"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				method("GetItem", "GetItemRequest", "Item", &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/items/{id}"}}, false),
+				method("UpdateItem", "UpdateItemRequest", "UpdateItemReply", &annotations.HttpRule{Pattern: &annotations.HttpRule_Patch{Patch: "/v1/items/{item.id}"}, Body: "item", ResponseBody: "item"}, false),
+				method("Ping", "Item", "Item", nil, false),
+				method("WatchItems", "GetItemRequest", "Item", &annotations.HttpRule{Pattern: &annotations.HttpRule_Get{Get: "/v1/items:watch"}}, true),
+			},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = protoregistry.GlobalFiles.RegisterFile(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the go types of the generated pb package
+	for i := 0; i < fd.Messages().Len(); i++ {
+		err = protoregistry.GlobalTypes.RegisterMessage(dynamicpb.NewMessageType(fd.Messages().Get(i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return fd
+}
+
+type itemService interface{}
+
+type itemServer struct {
+	fd protoreflect.FileDescriptor
+}
+
+func (s *itemServer) item(id, name string) proto.Message {
+	m := dynamicpb.NewMessage(s.fd.Messages().ByName("Item"))
+	m.Set(m.Descriptor().Fields().ByName("id"), protoreflect.ValueOfString(id))
+	m.Set(m.Descriptor().Fields().ByName("name"), protoreflect.ValueOfString(name))
+	return m
+}
+
+func get(m proto.Message, name string) protoreflect.Value {
+	r := m.ProtoReflect()
+	return r.Get(r.Descriptor().Fields().ByName(protoreflect.Name(name)))
+}
+
+func (s *itemServer) desc() *grpc.ServiceDesc {
+	unary := func(in string, call func(ctx context.Context, req proto.Message) (interface{}, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+		return func(_ interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+			req := dynamicpb.NewMessage(s.fd.Messages().ByName(protoreflect.Name(in)))
+			if err := dec(req); err != nil {
+				return nil, err
+			}
+			return interceptor(ctx, req, &grpc.UnaryServerInfo{}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return call(ctx, req.(proto.Message))
+			})
+		}
+	}
+	return &grpc.ServiceDesc{
+		ServiceName: "slark.transcode.test.ItemService",
+		HandlerType: (*itemService)(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "GetItem", Handler: unary("GetItemRequest", func(ctx context.Context, req proto.Message) (interface{}, error) {
+				id := get(req, "id").String()
+				if id == "0" {
+					return nil, status.Error(codes.NotFound, "item not found")
+				}
+				if id == "-1" {
+					return nil, errors.BadRequest("invalid id", "INVALID_ID")
+				}
+				md, _ := metadata.FromIncomingContext(ctx)
+				name := strings.Join(md.Get("x-name"), ",")
+				if get(req, "verbose").Bool() {
+					name += " verbose"
+				}
+				return s.item(id, name), nil
+			})},
+			{MethodName: "UpdateItem", Handler: unary("UpdateItemRequest", func(ctx context.Context, req proto.Message) (interface{}, error) {
+				item := get(req, "item").Message().Interface()
+				reply := dynamicpb.NewMessage(s.fd.Messages().ByName("UpdateItemReply"))
+				reply.Set(reply.Descriptor().Fields().ByName("item"), protoreflect.ValueOfMessage(s.item(get(item, "id").String(), get(item, "name").String()+" "+get(req, "mask").String()).ProtoReflect()))
+				return reply, nil
+			})},
+			{MethodName: "Ping", Handler: unary("Item", func(ctx context.Context, req proto.Message) (interface{}, error) {
+				return req, nil
+			})},
+		},
+		Streams: []grpc.StreamDesc{
+			{StreamName: "WatchItems", ServerStreams: true, Handler: func(_ interface{}, stream grpc.ServerStream) error {
+				req := dynamicpb.NewMessage(s.fd.Messages().ByName("GetItemRequest"))
+				if err := stream.RecvMsg(req); err != nil {
+					return err
+				}
+				for _, name := range []string{"a", "b"} {
+					if err := stream.SendMsg(s.item(get(req, "id").String(), name)); err != nil {
+						return err
+					}
+				}
+				if err := stream.RecvMsg(req); err != io.EOF {
+					return errors.InternalServer("request received twice", "RECV")
+				}
+				return nil
+			}},
+		},
+	}
+}
+
+func TestTranscode(t *testing.T) {
+	s := &itemServer{fd: registerItemService(t)}
+	srv := NewServer(Address("127.0.0.1:0"))
+	srv.RegisterService(s.desc(), s)
+
+	cases := []struct {
+		method string
+		path   string
+		body   string
+		header map[string]string
+		code   int
+		rsp    string
+	}{
+		{http.MethodGet, "/v1/items/1?verbose=true", "", map[string]string{"X-Name": "n"}, http.StatusOK, `{"code":0,"msg":"成功","data":{"id":"1","name":"n verbose"}}`},
+		{http.MethodGet, "/v1/items/0", "", nil, http.StatusNotFound, `{"code":404,"msg":"item not found","reason":"UNKNOWN_REASON","data":null}`},
+		{http.MethodGet, "/v1/items/-1", "", nil, http.StatusBadRequest, `{"code":400,"msg":"invalid id","reason":"INVALID_ID","data":null}`},
+		{http.MethodPatch, "/v1/items/2?mask=name", `{"name":"x"}`, map[string]string{"Content-Type": "application/json"}, http.StatusOK, `{"code":0,"msg":"成功","data":{"id":"2","name":"x name"}}`},
+		{http.MethodPost, "/slark.transcode.test.ItemService/Ping", `{"id":"3","name":"p"}`, map[string]string{"Content-Type": "application/json"}, http.StatusOK, `{"code":0,"msg":"成功","data":{"id":"3","name":"p"}}`},
+		{http.MethodGet, "/v1/items:watch?id=4", "", nil, http.StatusOK, "{\"id\":\"4\",\"name\":\"a\"}\n{\"id\":\"4\",\"name\":\"b\"}\n"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, strings.NewReader(c.body))
+		for k, v := range c.header {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, req)
+		// protojson randomizes the spaces
+		lines := strings.SplitAfter(rec.Body.String(), "\n")
+		for i, line := range lines {
+			var buf bytes.Buffer
+			if json.Compact(&buf, []byte(line)) == nil {
+				lines[i] = buf.String() + line[len(strings.TrimRight(line, "\n")):]
+			}
+		}
+		if rec.Code != c.code || strings.Join(lines, "") != c.rsp {
+			t.Fatalf("%s %s, code:%d, body:%s", c.method, c.path, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestTranscodeUnregistered(t *testing.T) {
+	fdp := &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("slark/transcode/test/untyped.proto"),
+		Package:     proto.String("slark.transcode.untyped"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Request")}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("UntypedService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Get"),
+				InputType:  proto.String(".slark.transcode.untyped.Request"),
+				OutputType: proto.String(".slark.transcode.untyped.Request"),
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(fdp, protoregistry.GlobalFiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = protoregistry.GlobalFiles.RegisterFile(fd); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		r := recover()
+		if r == nil || !strings.Contains(r.(string), "no registered go type of slark.transcode.untyped.Request") {
+			t.Fatalf("recover:%+v", r)
+		}
+	}()
+	NewServer(Address("127.0.0.1:0")).RegisterService(&grpc.ServiceDesc{
+		ServiceName: "slark.transcode.untyped.UntypedService",
+		HandlerType: (*itemService)(nil),
+		Methods:     []grpc.MethodDesc{{MethodName: "Get"}},
+	}, nil)
+}
+
+func TestTranscodeInterceptor(t *testing.T) {
+	var (
+		calls []string
+		md    metadata.MD
+	)
+	unary := func(name string) grpc.UnaryServerInterceptor {
+		return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, name)
+			md, _ = metadata.FromIncomingContext(ctx)
+			return handler(ctx, req)
+		}
+	}
+	stream := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		calls = append(calls, info.FullMethod)
+		return handler(srv, ss)
+	}
+	s := &itemServer{fd: registerItemService(t)}
+	srv := NewServer(Address("127.0.0.1:0"), UnaryInterceptor([]grpc.UnaryServerInterceptor{unary("a"), unary("b")}), StreamInterceptor([]grpc.StreamServerInterceptor{stream}))
+	srv.RegisterService(s.desc(), s)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/items/1", nil)
+	for k, v := range map[string]string{"X-Name": "n", "Connection": "keep-alive, X-Hop", "X-Hop": "1", "Grpc-Timeout": "1S", "Te": "trailers", "Content-Length": "0"} {
+		req.Header.Set(k, v)
+	}
+	rec := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || strings.Join(calls, ",") != "a,b" {
+		t.Fatalf("code:%d, calls:%+v", rec.Code, calls)
+	}
+	// the hop-by-hop and reserved headers are not passed
+	if len(md.Get("x-name")) != 1 || len(md.Get("connection")) != 0 || len(md.Get("x-hop")) != 0 || len(md.Get("grpc-timeout")) != 0 || len(md.Get("te")) != 0 || len(md.Get("content-length")) != 0 {
+		t.Fatalf("metadata:%+v", md)
+	}
+
+	calls = nil
+	rec = httptest.NewRecorder()
+	srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/items:watch?id=4", nil))
+	if rec.Code != http.StatusOK || strings.Join(calls, ",") != "/slark.transcode.test.ItemService/WatchItems" {
+		t.Fatalf("code:%d, calls:%+v", rec.Code, calls)
+	}
+}
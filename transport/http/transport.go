@@ -2,6 +2,7 @@ package http
 
 import (
 	"github.com/go-slark/slark/transport"
+	"github.com/go-slark/slark/transport/grpc/balancer/node"
 	"net/http"
 )
 
@@ -35,6 +36,12 @@ type Transport struct {
 	Operation string
 	Req       Carrier
 	Rsp       Carrier
+	filters   []node.Filter
+}
+
+// Filter :node filters of the client request
+func (t *Transport) Filter() []node.Filter {
+	return t.filters
 }
 
 func (t *Transport) Kind() string {
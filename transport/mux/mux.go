@@ -0,0 +1,337 @@
+package mux
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/go-slark/slark/logger"
+	"github.com/go-slark/slark/pkg/endpoint"
+	"github.com/go-slark/slark/transport"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+var _ transport.Server = (*Server)(nil)
+var _ transport.Endpoints = (*Server)(nil)
+var _ transport.Contextual = (*Server)(nil)
+
+// Server serves http/1.1, h2c grpc and websocket on a single listener by sniffing the first request of each connection,
+// the connections are routed to the listeners of the kinds, such as
+//
+//	srv := mux.NewServer(mux.Address("0.0.0.0:8000"))
+//	hs := http.NewServer(http.Listener(srv.Listener(transport.HTTP)))
+//	gs := grpc.NewServer(grpc.Listener(srv.Listener(transport.GRPC)))
+//	ws := ws.NewServer(ws.Listener(srv.Listener(transport.WS)), ws.Path("/ws"))
+//	srv.Add(hs, gs, ws)
+//	slark.NewApp(slark.Server(srv))
+//
+// the added servers are started and stopped by the mux, the connections are in plaintext,
+// so tls is terminated by the ingress, /metrics is served by hs.Engine() instead of :8081
+type Server struct {
+	listener  net.Listener
+	network   string
+	address   string
+	timeout   time.Duration
+	logger    logger.Logger
+	listeners map[string]*listener
+	servers   []transport.Server
+	err       error
+	closed    chan struct{}
+	ready     chan struct{}
+	once      sync.Once
+	stop      sync.Once
+	l         sync.RWMutex
+}
+
+type ServerOption func(*Server)
+
+func Network(network string) ServerOption {
+	return func(s *Server) {
+		s.network = network
+	}
+}
+
+func Address(addr string) ServerOption {
+	return func(s *Server) {
+		s.address = addr
+	}
+}
+
+// Timeout :max time reading the first request of a connection
+func Timeout(tm time.Duration) ServerOption {
+	return func(s *Server) {
+		s.timeout = tm
+	}
+}
+
+func Logger(l logger.Logger) ServerOption {
+	return func(s *Server) {
+		s.logger = l
+	}
+}
+
+func NewServer(opts ...ServerOption) *Server {
+	srv := &Server{
+		network:   "tcp",
+		address:   "0.0.0.0:8000",
+		timeout:   10 * time.Second,
+		logger:    logger.GetLogger(),
+		listeners: make(map[string]*listener),
+		closed:    make(chan struct{}),
+		ready:     make(chan struct{}),
+	}
+	for _, o := range opts {
+		o(srv)
+	}
+	srv.err = srv.listen()
+	return srv
+}
+
+func (s *Server) listen() error {
+	l, err := net.Listen(s.network, s.address)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	return nil
+}
+
+// Listener returns the listener of the connections of kind, transport.HTTP, transport.GRPC or transport.WS
+func (s *Server) Listener(kind string) net.Listener {
+	s.l.Lock()
+	defer s.l.Unlock()
+	l, ok := s.listeners[kind]
+	if !ok {
+		l = &listener{addr: s.addr(), conns: make(chan net.Conn), done: make(chan struct{})}
+		s.listeners[kind] = l
+	}
+	return l
+}
+
+func (s *Server) addr() net.Addr {
+	if s.listener == nil {
+		return &net.TCPAddr{}
+	}
+	return s.listener.Addr()
+}
+
+// Add adds the servers serving on the listeners of the mux, they are started and stopped by the mux
+func (s *Server) Add(srv ...transport.Server) {
+	s.servers = append(s.servers, srv...)
+}
+
+func (s *Server) Start() error {
+	if s.err != nil {
+		return s.err
+	}
+	eg := errgroup.Group{}
+	for _, srv := range s.servers {
+		srv := srv
+		eg.Go(srv.Start)
+	}
+	eg.Go(s.serve)
+	s.once.Do(func() {
+		close(s.ready)
+	})
+	return eg.Wait()
+}
+
+func (s *Server) serve() error {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			select {
+			case <-s.closed:
+				return nil
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				time.Sleep(5 * time.Millisecond)
+				continue
+			}
+			return err
+		}
+		go s.route(conn)
+	}
+}
+
+// route sniffs the first request of the connection, the h2c preface is grpc, an upgrade to websocket is ws
+func (s *Server) route(conn net.Conn) {
+	buf := &bytes.Buffer{}
+	_ = conn.SetReadDeadline(time.Now().Add(s.timeout))
+	req, err := http.ReadRequest(bufio.NewReader(io.TeeReader(conn, buf)))
+	_ = conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		s.logger.Log(context.TODO(), logger.DebugLevel, map[string]interface{}{"error": err, "remote": conn.RemoteAddr().String()}, "mux sniff connection fail")
+		_ = conn.Close()
+		return
+	}
+	kind := transport.HTTP
+	switch {
+	case req.Method == "PRI" && req.Proto == "HTTP/2.0":
+		kind = transport.GRPC
+	case strings.EqualFold(req.Header.Get("Upgrade"), "websocket"):
+		kind = transport.WS
+	}
+	s.l.RLock()
+	l, ok := s.listeners[kind]
+	if !ok {
+		l, ok = s.listeners[transport.HTTP]
+	}
+	s.l.RUnlock()
+	if !ok {
+		_ = conn.Close()
+		return
+	}
+	l.deliver(&sniffConn{Conn: conn, r: io.MultiReader(buf, conn)})
+}
+
+// SetBaseContext sets the base context of the added servers
+func (s *Server) SetBaseContext(ctx context.Context) {
+	for _, srv := range s.servers {
+		if c, ok := srv.(transport.Contextual); ok {
+			c.SetBaseContext(ctx)
+		}
+	}
+}
+
+// Ready waits for the mux and the added servers
+func (s *Server) Ready(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	eg, cx := errgroup.WithContext(ctx)
+	for _, srv := range s.servers {
+		r, ok := srv.(transport.Ready)
+		if !ok {
+			continue
+		}
+		eg.Go(func() error {
+			return r.Ready(cx)
+		})
+	}
+	return eg.Wait()
+}
+
+// Stop stops accepting and shuts down the added servers gracefully
+func (s *Server) Stop(ctx context.Context) error {
+	var err error
+	s.stop.Do(func() {
+		close(s.closed)
+		if s.listener != nil {
+			err = s.listener.Close()
+		}
+	})
+	errs := make([]error, len(s.servers))
+	wg := sync.WaitGroup{}
+	for i, srv := range s.servers {
+		wg.Add(1)
+		go func(i int, srv transport.Server) {
+			defer wg.Done()
+			errs[i] = srv.Stop(ctx)
+		}(i, srv)
+	}
+	wg.Wait()
+	s.l.RLock()
+	for _, l := range s.listeners {
+		_ = l.Close()
+	}
+	s.l.RUnlock()
+	return errors.Join(append(errs, err)...)
+}
+
+// Endpoint returns the http endpoint, or the first of Endpoints
+func (s *Server) Endpoint() (*url.URL, error) {
+	us, err := s.Endpoints()
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range us {
+		if u.Scheme == transport.HTTP {
+			return u, nil
+		}
+	}
+	if len(us) == 0 {
+		return nil, errors.New("mux no listener")
+	}
+	return us[0], nil
+}
+
+// Endpoints returns an endpoint per scheme of the listeners, sorted by http, grpc, ws
+func (s *Server) Endpoints() ([]*url.URL, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	host, err := endpoint.ParseAddr(s.listener, s.address)
+	if err != nil {
+		return nil, err
+	}
+	s.l.RLock()
+	defer s.l.RUnlock()
+	us := make([]*url.URL, 0, len(s.listeners))
+	for _, kind := range []string{transport.HTTP, transport.GRPC, transport.WS} {
+		if _, ok := s.listeners[kind]; ok {
+			us = append(us, &url.URL{Scheme: kind, Host: host})
+		}
+	}
+	return us, nil
+}
+
+// listener is the net.Listener of the routed connections
+type listener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func (l *listener) deliver(conn net.Conn) {
+	select {
+	case l.conns <- conn:
+	case <-l.done:
+		_ = conn.Close()
+	}
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *listener) Close() error {
+	l.once.Do(func() {
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.addr
+}
+
+// sniffConn replays the sniffed bytes before reading the connection
+type sniffConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *sniffConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
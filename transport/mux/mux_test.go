@@ -0,0 +1,107 @@
+package mux
+
+import (
+	"context"
+	"github.com/gin-gonic/gin"
+	"github.com/go-slark/slark/transport"
+	"github.com/go-slark/slark/transport/grpc"
+	thttp "github.com/go-slark/slark/transport/http"
+	"github.com/go-slark/slark/transport/ws"
+	"github.com/gorilla/websocket"
+	ggrpc "google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMux(t *testing.T) {
+	gin.SetMode(gin.ReleaseMode)
+	srv := NewServer(Address("127.0.0.1:0"))
+	hs := thttp.NewServer(thttp.Listener(srv.Listener(transport.HTTP)))
+	hs.Engine().GET("/ping", func(c *gin.Context) {
+		c.String(http.StatusOK, "pong")
+	})
+	gs := grpc.NewServer(grpc.Listener(srv.Listener(transport.GRPC)))
+	wss := ws.NewServer(ws.Listener(srv.Listener(transport.WS)), ws.Path("/mux/ws"))
+	wss.Handler(func(s *ws.Session) {
+		msg, err := s.Receive()
+		if err != nil || msg == nil {
+			return
+		}
+		_ = s.Send(msg)
+		time.Sleep(100 * time.Millisecond)
+	})
+	srv.Add(hs, gs, wss)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- srv.Start()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := srv.Ready(ctx); err != nil {
+		t.Fatal(err)
+	}
+	addr := srv.listener.Addr().String()
+
+	rsp, err := http.Get("http://" + addr + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(rsp.Body)
+	_ = rsp.Body.Close()
+	if string(body) != "pong" {
+		t.Fatalf("http body:%s", body)
+	}
+
+	conn, err := ggrpc.Dial(addr, ggrpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	hc, err := grpc_health_v1.NewHealthClient(conn).Check(ctx, &grpc_health_v1.HealthCheckRequest{})
+	if err != nil || hc.Status != grpc_health_v1.HealthCheckResponse_SERVING {
+		t.Fatalf("grpc health:%v, err:%v", hc, err)
+	}
+
+	wc, _, err := websocket.DefaultDialer.DialContext(ctx, "ws://"+addr+"/mux/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = wc.WriteMessage(websocket.TextMessage, []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, msg, err := wc.ReadMessage()
+	_ = wc.Close()
+	if err != nil || string(msg) != "hello" {
+		t.Fatalf("ws msg:%s, err:%v", msg, err)
+	}
+
+	us, err := srv.Endpoints()
+	if err != nil || len(us) != 3 {
+		t.Fatalf("endpoints:%v, err:%v", us, err)
+	}
+	for i, scheme := range []string{"http", "grpc", "ws"} {
+		if us[i].Scheme != scheme || !strings.HasSuffix(us[i].Host, addr[strings.LastIndex(addr, ":"):]) {
+			t.Fatalf("endpoint:%s", us[i])
+		}
+	}
+
+	err = srv.Stop(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal("mux not stopped")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	_ "github.com/go-slark/slark/encoding/json"
 	_ "github.com/go-slark/slark/encoding/msgpack"
 	_ "github.com/go-slark/slark/encoding/proto"
+	_ "github.com/go-slark/slark/encoding/xml"
 )
 
 type Server interface {
@@ -19,9 +20,25 @@ type Endpoint interface {
 	Endpoint() (*url.URL, error)
 }
 
+// Endpoints is optionally implemented by Server serving several schemes, such as mux.Server
+type Endpoints interface {
+	Endpoints() ([]*url.URL, error)
+}
+
+// Ready is optionally implemented by Server, it blocks until the server is serving
+type Ready interface {
+	Ready(ctx context.Context) error
+}
+
+// Contextual is optionally implemented by Server, the requests served carry the values of the base context, such as the app info
+type Contextual interface {
+	SetBaseContext(ctx context.Context)
+}
+
 const (
 	HTTP = "http"
 	GRPC = "grpc"
+	WS   = "ws"
 )
 
 type Carrier interface {
@@ -60,3 +77,25 @@ func FromServerContext(ctx context.Context) (Transporter, bool) {
 	trans, ok := ctx.Value(serverContextKey{}).(Transporter)
 	return trans, ok
 }
+
+type valueContext struct {
+	context.Context
+	base context.Context
+}
+
+func (c *valueContext) Value(key interface{}) interface{} {
+	v := c.Context.Value(key)
+	if v != nil {
+		return v
+	}
+	return c.base.Value(key)
+}
+
+// ValueContext returns ctx looking up the values missed in base, the deadline and cancellation are of ctx only,
+// such as ValueContext(context.Background(), ctx) takes the values of ctx without its cancellation
+func ValueContext(ctx, base context.Context) context.Context {
+	if base == nil {
+		return ctx
+	}
+	return &valueContext{Context: ctx, base: base}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/go-slark/slark/logger"
 	"github.com/go-slark/slark/transport/http/handler"
+	"net"
 	"net/http"
 	"time"
 )
@@ -28,6 +29,13 @@ func Address(addr string) ServerOption {
 	}
 }
 
+// Listener serves on l instead of listening on the address, such as the listener of mux.Server
+func Listener(l net.Listener) ServerOption {
+	return func(s *Server) {
+		s.listener = l
+	}
+}
+
 func Timeout(rTimeout, wTimeout time.Duration) ServerOption {
 	return func(s *Server) {
 		s.Server.ReadTimeout = rTimeout
@@ -38,6 +38,8 @@ type Server struct {
 	address  string
 	path     string
 	err      error
+	ready    chan struct{}
+	once     sync.Once
 }
 
 func NewServer(opts ...ServerOption) *Server {
@@ -58,6 +60,7 @@ func NewServer(opts ...ServerOption) *Server {
 		},
 		network: "tcp",
 		address: "0.0.0.0:0",
+		ready:   make(chan struct{}),
 		logger:  logger.GetLogger(),
 		before: func(ctx context.Context, req *http.Request) (interface{}, error) {
 			return nil, nil
@@ -136,6 +139,9 @@ func (s *Server) Start() error {
 		return s.err
 	}
 	http.Handle(s.path, handler.ComposeMiddleware(s.handler, s.handlers...))
+	s.once.Do(func() {
+		close(s.ready)
+	})
 	err := s.Serve(s.listener)
 	if !errors.Is(err, http.ErrServerClosed) {
 		return err
@@ -147,7 +153,29 @@ func (s *Server) Stop(ctx context.Context) error {
 	return s.Shutdown(ctx)
 }
 
+// SetBaseContext sets the base context of the requests served, it is called by the app before Start
+func (s *Server) SetBaseContext(ctx context.Context) {
+	s.BaseContext = func(net.Listener) context.Context {
+		return ctx
+	}
+}
+
+func (s *Server) Ready(ctx context.Context) error {
+	if s.err != nil {
+		return s.err
+	}
+	select {
+	case <-s.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func (s *Server) listen() error {
+	if s.listener != nil {
+		return nil
+	}
 	l, err := net.Listen(s.network, s.address)
 	if err != nil {
 		return err